@@ -22,9 +22,21 @@ if [ -e /home/dv/updater/dv-updater.service ] && ! [ -e /etc/systemd/system/dv-u
    cp /home/dv/updater/dv-updater.service /etc/systemd/system/dv-updater.service
 fi
 
+# apk keys are named after the signing key, sudoers cannot pin an unknown name, so the key is installed here
+if [ -n "${DV_APK_KEY}" ] && [ -d /etc/apk ]; then
+  apk_key_path="/etc/apk/keys/$(basename "${DV_APK_KEY}")"
+  if ! [ -e "$apk_key_path" ]; then
+    echo "Installing apk repository key $apk_key_path..."
+    case "${DV_APK_KEY}" in
+      http://*|https://*) wget -q -O "$apk_key_path" "${DV_APK_KEY}" ;;
+      *) cp "${DV_APK_KEY}" "$apk_key_path" ;;
+    esac
+  fi
+fi
+
 echo "Configuring sudoers for $dv_user..."
 cat > /etc/sudoers.d/dv-updater << EOF
-$dv_user ALL=(ALL) NOPASSWD: /usr/bin/apt *, /usr/bin/yum *, /usr/bin/systemctl stop dv-updater.service, /usr/bin/systemctl start dv-updater.service, /usr/bin/systemctl enable dv-updater.service, /usr/bin/systemctl restart dv-updater.service, /usr/bin/systemctl try-restart dv-updater.service, /usr/bin/dpkg *
+$dv_user ALL=(ALL) NOPASSWD: /usr/bin/apt *, /usr/bin/yum *, /usr/bin/dnf *, /usr/bin/dnf5 *, /usr/bin/zypper *, /sbin/apk *, /usr/bin/systemctl stop dv-updater.service, /usr/bin/systemctl start dv-updater.service, /usr/bin/systemctl enable dv-updater.service, /usr/bin/systemctl restart dv-updater.service, /usr/bin/systemctl try-restart dv-updater.service, /usr/bin/dpkg *, /usr/bin/tee /etc/apt/sources.list.d/dvnet.list, /usr/bin/tee /etc/yum.repos.d/dvnet.repo, /usr/bin/tee /etc/zypp/repos.d/dvnet.repo
 EOF
 chmod 440 /etc/sudoers.d/dv-updater
 
@@ -0,0 +1,174 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/http/middleware"
+	"github.com/dv-net/dv-updater/internal/http/request"
+	"github.com/dv-net/dv-updater/internal/http/response"
+	"github.com/dv-net/dv-updater/internal/service/job"
+)
+
+const jobPollInterval = time.Second
+
+// daemonClient calls the API of the running updater. Console operations run as its jobs, so they wait
+// for the operation lock and share the rollback and audit state instead of racing the daemon.
+type daemonClient struct {
+	client  *http.Client
+	baseURL string
+	auth    config.HTTPAuthConfig
+}
+
+// newDaemonClient prefers the unix socket, the TCP listener may require a client certificate.
+func newDaemonClient(conf config.HTTPConfig) (*daemonClient, error) {
+	switch {
+	case conf.Socket.Enabled:
+		var dialer net.Dialer
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", conf.Socket.Path)
+			},
+		}
+		return &daemonClient{client: &http.Client{Transport: transport}, baseURL: "http://localhost", auth: conf.Auth}, nil
+	case conf.TCPEnabled:
+		host := conf.Host
+		if host == "" {
+			host = "localhost"
+		}
+		scheme := "http"
+		if conf.TLS.Enabled {
+			scheme = "https"
+		}
+		return &daemonClient{client: http.DefaultClient, baseURL: scheme + "://" + net.JoinHostPort(host, conf.Port), auth: conf.Auth}, nil
+	default:
+		return nil, errors.New("the updater API is not served, use --local while the updater is stopped")
+	}
+}
+
+// update queues an update job and follows it until it finishes.
+func (c *daemonClient) update(ctx context.Context, name, version string) (job.Job, error) {
+	return c.runJob(ctx, "/api/v1/update", request.UpdatePackageRequest{Name: name, Version: version})
+}
+
+// rollback queues a rollback job and follows it until it finishes.
+func (c *daemonClient) rollback(ctx context.Context, name string) (job.Job, error) {
+	return c.runJob(ctx, "/api/v1/rollback", request.RollbackPackageRequest{Name: name})
+}
+
+// runJob posts the request and prints the output of the queued job until it is finished.
+func (c *daemonClient) runJob(ctx context.Context, uri string, body any) (job.Job, error) {
+	var j job.Job
+	if err := c.do(ctx, http.MethodPost, uri, body, &j); err != nil {
+		return job.Job{}, err
+	}
+
+	printed := 0
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// the output is truncated from the front once it exceeds the job limit
+		if len(j.Output) < printed {
+			printed = 0
+		}
+		_, _ = io.WriteString(os.Stdout, j.Output[printed:])
+		printed = len(j.Output)
+
+		if j.Finished() {
+			if j.State == job.StateFailed {
+				return j, errors.New(j.Error)
+			}
+			return j, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return j, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if err := c.do(ctx, http.MethodGet, "/api/v1/jobs/"+j.ID.String(), nil, &j); err != nil {
+			return j, fmt.Errorf("follow job %s: %w", j.ID, err)
+		}
+	}
+}
+
+func (c *daemonClient) do(ctx context.Context, method, uri string, body, data any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+uri, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.authorize(req, uri, payload)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("updater is not reachable: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var res response.Result[json.RawMessage]
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("unexpected response %s: %w", resp.Status, err)
+	}
+	// handlers report failures in the code of the body
+	if resp.StatusCode != http.StatusOK || (res.Code != 0 && res.Code != http.StatusOK) {
+		return fmt.Errorf("updater rejected the request: %s", res.Message)
+	}
+
+	return json.Unmarshal(res.Data, data)
+}
+
+// authorize uses the first configured credentials, static tokens and the shared secret grant every scope.
+func (c *daemonClient) authorize(req *http.Request, uri string, body []byte) {
+	if !c.auth.Enabled {
+		return
+	}
+
+	switch {
+	case len(c.auth.Tokens) > 0:
+		req.Header.Set("Authorization", "Bearer "+c.auth.Tokens[0])
+		return
+	case c.auth.HMACSecret != "":
+		sign(req, c.auth.HMACSecret, uri, body)
+		return
+	}
+
+	for _, key := range c.auth.Keys {
+		switch {
+		case key.Token != "":
+			req.Header.Set("Authorization", "Bearer "+key.Token)
+			return
+		case key.HMACSecret != "":
+			req.Header.Set(middleware.HeaderKeyID, key.ID)
+			sign(req, key.HMACSecret, uri, body)
+			return
+		}
+	}
+}
+
+func sign(req *http.Request, secret, uri string, body []byte) {
+	ts := time.Now().Unix()
+	req.Header.Set(middleware.HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(middleware.HeaderSignature, hex.EncodeToString(middleware.Sign(secret, ts, req.Method, uri, body)))
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"text/tabwriter"
 
 	"github.com/dv-net/dv-updater/internal/app"
 	"github.com/dv-net/dv-updater/internal/config"
 	"github.com/dv-net/dv-updater/internal/distro"
 	"github.com/dv-net/dv-updater/internal/service"
+	"github.com/dv-net/dv-updater/internal/service/audit"
+	"github.com/dv-net/dv-updater/internal/service/package_manager"
+	"github.com/dv-net/dv-updater/internal/service/updater"
 	"github.com/dv-net/dv-updater/pkg/logger"
 	"github.com/dv-net/xconfig"
 	"github.com/goccy/go-yaml"
@@ -52,11 +56,15 @@ func InitCommands(currentAppVersion, currentAppCommitHash string) []*cli.Command
 					return err
 				}
 
-				svc, err := service.NewServices(l, dist, currentAppVersion, currentAppCommitHash)
+				svc, err := service.NewServices(conf, l, dist, currentAppVersion, currentAppCommitHash)
 				if err != nil {
 					return err
 				}
 
+				if err = svc.ChannelService.Apply(ctx.Context); err != nil {
+					return err
+				}
+
 				if err = app.SelfUpdate(ctx.Context, &conf.AutoUpdate, svc, l); err != nil {
 					l.Error("self update failed", err)
 				}
@@ -64,6 +72,170 @@ func InitCommands(currentAppVersion, currentAppCommitHash string) []*cli.Command
 				return nil
 			},
 		},
+		{
+			Name:        "update",
+			Description: "Upgrade a managed package or install an exact version of it",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "name",
+					Usage:    "package to update",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "version",
+					Usage: "exact version to install, the latest one by default",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only print what the package manager would change",
+				},
+				&cli.BoolFlag{
+					Name:  "local",
+					Usage: "update in this process instead of queueing a job in the running updater, only while it is stopped",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				conf, err := loadConfig(ctx.Args().Slice(), ctx.StringSlice("configs"))
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
+
+				name, version := ctx.String("name"), ctx.String("version")
+				if !ctx.Bool("dry-run") && !ctx.Bool("local") {
+					client, err := newDaemonClient(conf.HTTP)
+					if err != nil {
+						return err
+					}
+
+					j, err := client.update(ctx.Context, name, version)
+					if err != nil {
+						return fmt.Errorf("update failed: %w", err)
+					}
+					if j.Result != nil {
+						_, _ = fmt.Fprintf(os.Stdout, "%s is at %s\n", j.Result.Name, j.Result.InstalledVersion)
+					}
+					return nil
+				}
+
+				l := logger.New(currentAppVersion, conf.Log)
+				l.Info("Logger Init")
+
+				d := distro.New(l)
+				dist, err := d.DiscoverDistro()
+				if err != nil {
+					return err
+				}
+
+				svc, err := service.NewServices(conf, l, dist, currentAppVersion, currentAppCommitHash)
+				if err != nil {
+					return err
+				}
+
+				if err = svc.ChannelService.Apply(ctx.Context); err != nil {
+					return err
+				}
+
+				if err = svc.CatalogService.ValidateManaged(name); err != nil {
+					return err
+				}
+
+				if ctx.Bool("dry-run") {
+					plan, err := svc.PackageManager.SimulateUpgrade(ctx.Context, name, version)
+					if err != nil {
+						return fmt.Errorf("dry run failed: %w", err)
+					}
+
+					printUpgradePlan(plan)
+					return nil
+				}
+
+				defer svc.WebhookService.Flush(ctx.Context)
+
+				var res updater.Result
+				runCtx := audit.WithRequester(ctx.Context, "console")
+				if version != "" {
+					res, err = svc.UpdaterService.Install(runCtx, name, version, os.Stdout)
+				} else {
+					res, err = svc.UpdaterService.Upgrade(runCtx, name, os.Stdout)
+				}
+				if err != nil {
+					return fmt.Errorf("update failed: %w", err)
+				}
+
+				_, _ = fmt.Fprintf(os.Stdout, "%s is at %s\n", res.Package.Name, res.Package.InstalledVersion)
+				return nil
+			},
+		},
+		{
+			Name:        "rollback",
+			Description: "Reinstall the version a package had before its last upgrade",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "name",
+					Usage:    "package to roll back",
+					Required: true,
+				},
+				&cli.BoolFlag{
+					Name:  "local",
+					Usage: "roll back in this process instead of queueing a job in the running updater, only while it is stopped",
+				},
+			},
+			Action: func(ctx *cli.Context) error {
+				conf, err := loadConfig(ctx.Args().Slice(), ctx.StringSlice("configs"))
+				if err != nil {
+					return fmt.Errorf("failed to load config: %w", err)
+				}
+
+				name := ctx.String("name")
+				if !ctx.Bool("local") {
+					client, err := newDaemonClient(conf.HTTP)
+					if err != nil {
+						return err
+					}
+
+					j, err := client.rollback(ctx.Context, name)
+					if err != nil {
+						return fmt.Errorf("rollback failed: %w", err)
+					}
+					if j.Result != nil {
+						_, _ = fmt.Fprintf(os.Stdout, "%s rolled back to %s\n", j.Result.Name, j.Result.InstalledVersion)
+					}
+					return nil
+				}
+
+				l := logger.New(currentAppVersion, conf.Log)
+				l.Info("Logger Init")
+
+				d := distro.New(l)
+				dist, err := d.DiscoverDistro()
+				if err != nil {
+					return err
+				}
+
+				svc, err := service.NewServices(conf, l, dist, currentAppVersion, currentAppCommitHash)
+				if err != nil {
+					return err
+				}
+
+				if err = svc.ChannelService.Apply(ctx.Context); err != nil {
+					return err
+				}
+
+				if err = svc.CatalogService.Validate(name); err != nil {
+					return err
+				}
+
+				defer svc.WebhookService.Flush(ctx.Context)
+
+				res, err := svc.UpdaterService.Rollback(audit.WithRequester(ctx.Context, "console"), name, os.Stdout)
+				if err != nil {
+					return fmt.Errorf("rollback failed: %w", err)
+				}
+
+				_, _ = fmt.Fprintf(os.Stdout, "%s rolled back to %s\n", res.Package.Name, res.Package.InstalledVersion)
+				return nil
+			},
+		},
 		{
 			Name:        "version",
 			Description: "print DV updater server version",
@@ -114,6 +286,20 @@ func prepareConfigCommands() []*cli.Command {
 	}
 }
 
+func printUpgradePlan(plan package_manager.UpgradePlan) {
+	if !plan.Changed() {
+		_, _ = fmt.Fprintf(os.Stdout, "%s: nothing to change\n", plan.Package)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ACTION\tPACKAGE\tCURRENT\tNEW\tREPOSITORY")
+	for _, c := range plan.Changes {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Action, c.Name, c.CurrentVersion, c.Version, c.Repository)
+	}
+	_ = w.Flush()
+}
+
 func loadConfig(_, configPaths []string) (*config.Config, error) {
 	conf, err := config.Load[config.Config](configPaths, envPrefix)
 	if err != nil {
@@ -19,11 +19,20 @@ func Run(ctx context.Context, conf *config.Config, l logger.Logger, currentAppVe
 		return err
 	}
 
-	svc, err := service.NewServices(l, dist, currentAppVersion, currentAppCommitHash)
+	svc, err := service.NewServices(conf, l, dist, currentAppVersion, currentAppCommitHash)
 	if err != nil {
 		return err
 	}
 
+	if err = svc.ChannelService.Apply(ctx); err != nil {
+		return err
+	}
+
+	svc.WebhookService.Run(ctx)
+	svc.UpdaterService.CompleteSelfUpdate(ctx)
+
+	go svc.JobService.Run(ctx)
+
 	if err = initTickers(ctx, svc, l, &conf.AutoUpdate); err != nil {
 		return err
 	}
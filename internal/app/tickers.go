@@ -3,16 +3,25 @@ package app
 import (
 	"context"
 	"errors"
+	"io"
 	"time"
 
 	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/metrics"
 	"github.com/dv-net/dv-updater/internal/service"
+	"github.com/dv-net/dv-updater/internal/service/audit"
+	"github.com/dv-net/dv-updater/internal/service/coordinator"
+	"github.com/dv-net/dv-updater/internal/service/package_manager"
 	"github.com/dv-net/dv-updater/pkg/logger"
 )
 
 func initTickers(ctx context.Context, s *service.Services, l logger.Logger, conf *config.AutoUpdateConfig) error {
 	if s.PackageManager != nil {
-		go autoUpdatePackages(ctx, s, l)
+		go autoUpdatePackages(ctx, s, l, conf)
+
+		if conf.Enabled {
+			go s.SchedulerService.Run(ctx)
+		}
 
 		go func() {
 			ticker := time.NewTicker(time.Second * 10)
@@ -33,7 +42,7 @@ func initTickers(ctx context.Context, s *service.Services, l logger.Logger, conf
 	return nil
 }
 
-func autoUpdatePackages(ctx context.Context, s *service.Services, l logger.Logger) {
+func autoUpdatePackages(ctx context.Context, s *service.Services, l logger.Logger, conf *config.AutoUpdateConfig) {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
@@ -42,9 +51,56 @@ func autoUpdatePackages(ctx context.Context, s *service.Services, l logger.Logge
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := s.PackageManager.UpdateRepository(ctx); err != nil {
+			if err := refreshRepository(ctx, s); err != nil {
 				l.Error("Repository update failed: %v", err)
+				continue
 			}
+
+			observeVersions(ctx, s)
+
+			if conf.Enabled {
+				s.SchedulerService.Observe(ctx)
+			}
+		}
+	}
+}
+
+func refreshRepository(ctx context.Context, s *service.Services) error {
+	ctx, release, err := s.CoordinatorService.Acquire(ctx, coordinator.Operation{
+		Name:     string(audit.OperationRepositoryRefresh),
+		Priority: coordinator.PriorityNormal,
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	record := s.AuditService.Begin(ctx, audit.OperationRepositoryRefresh, "")
+	err = s.PackageManager.UpdateRepository(ctx)
+	metrics.ObserveRepositoryRefresh(record.Time, err)
+	// refreshes run every minute, only failures are worth a record
+	if err == nil {
+		return nil
+	}
+
+	if auditErr := s.AuditService.Finish(record, nil, err); auditErr != nil {
+		return errors.Join(err, auditErr)
+	}
+
+	return err
+}
+
+// observeVersions refreshes the package status snapshot, exports the installed and available versions
+// of the updater and managed packages and notifies webhooks about new versions.
+func observeVersions(ctx context.Context, s *service.Services) {
+	for _, pkg := range s.StatusService.Refresh(ctx).Packages {
+		if pkg.Error != "" {
+			continue
+		}
+
+		metrics.SetPackageVersions(pkg.Name, pkg.InstalledVersion, pkg.AvailableVersion, pkg.NeedForUpdate)
+		if pkg.NeedForUpdate {
+			s.WebhookService.UpdateAvailable(pkg.Name, pkg.InstalledVersion, pkg.AvailableVersion)
 		}
 	}
 }
@@ -54,17 +110,29 @@ func SelfUpdate(ctx context.Context, conf *config.AutoUpdateConfig, s *service.S
 		return errors.New("auto-update is disabled")
 	}
 
-	updates, err := s.PackageManager.CheckForUpdates(ctx, service.DVUpdaterServiceName)
+	updates, err := s.StatusService.Package(ctx, service.DVUpdaterServiceName)
 	if err != nil {
+		metrics.SelfUpdateCheck(metrics.SelfUpdateError)
 		l.Error("self update new version check", err)
 		return err
 	}
 
-	if updates.AvailableVersion != "" && updates.InstalledVersion != updates.AvailableVersion {
-		if err = s.PackageManager.UpgradePackage(ctx, service.DVUpdaterServiceName); err != nil {
-			l.Error("self update upgrade failed", err)
-			return err
-		}
+	if !updates.NeedForUpdate {
+		metrics.SelfUpdateCheck(metrics.SelfUpdateUpToDate)
+		return nil
+	}
+
+	if s.ChannelService.Get(service.DVUpdaterServiceName) == package_manager.ChannelStable && updates.UpgradesToPreRelease() {
+		metrics.SelfUpdateCheck(metrics.SelfUpdatePreRelease)
+		l.Debug("self update skips pre-release", "version", updates.AvailableVersion)
+		return nil
+	}
+
+	metrics.SelfUpdateCheck(metrics.SelfUpdateAvailable)
+
+	if _, err = s.UpdaterService.Upgrade(audit.WithRequester(ctx, "self-update"), service.DVUpdaterServiceName, io.Discard); err != nil {
+		l.Error("self update upgrade failed", err)
+		return err
 	}
 
 	return nil
@@ -12,10 +12,16 @@ type (
 		HTTP       HTTPConfig       `yaml:"http"`
 		Log        logger.Config    `yaml:"log"`
 		AutoUpdate AutoUpdateConfig `yaml:"auto_update"`
+		Jobs       JobsConfig       `yaml:"jobs"`
+		Packages   []PackageConfig  `yaml:"packages" validate:"dive"`
+		Repository RepositoryConfig `yaml:"repository"`
+		Audit      AuditConfig      `yaml:"audit"`
+		Webhooks   WebhooksConfig   `yaml:"webhooks"`
 	}
 
 	AppConfig struct {
 		Profile string `yaml:"profile" default:"dev"`
+		DataDir string `yaml:"data_dir" env:"DATA_DIR" default:"/home/dv/updater/data" usage:"directory for the updater state files"`
 	}
 
 	HTTPCorsConfig struct {
@@ -24,14 +30,49 @@ type (
 	}
 
 	HTTPConfig struct {
-		Host               string         `yaml:"host" default:"localhost"`
-		Port               string         `yaml:"port" default:"8081"`
-		FetchInterval      time.Duration  `yaml:"fetch_interval" env:"FETCH_INTERVAL" default:"30s"`
-		ConnectTimeout     time.Duration  `yaml:"connect_timeout" env:"CONNECT_TIMEOUT" default:"5s"`
-		ReadTimeout        time.Duration  `yaml:"read_timeout" env:"READ_TIMEOUT" default:"10s"`
-		WriteTimeout       time.Duration  `yaml:"write_timeout" env:"WRITE_TIMEOUT" default:"10s"`
-		MaxHeaderMegabytes int            `yaml:"max_header_megabytes" env:"MAX_HEADER_MEGABYTES" default:"1"`
-		Cors               HTTPCorsConfig `yaml:"cors"`
+		TCPEnabled         bool             `yaml:"tcp_enabled" env:"TCP_ENABLED" default:"true" usage:"serve the API on host:port"`
+		Host               string           `yaml:"host" default:"localhost" usage:"address the TCP listener binds to, all interfaces when empty"`
+		Port               string           `yaml:"port" default:"8081"`
+		Socket             HTTPSocketConfig `yaml:"socket"`
+		TLS                HTTPTLSConfig    `yaml:"tls"`
+		FetchInterval      time.Duration    `yaml:"fetch_interval" env:"FETCH_INTERVAL" default:"30s" usage:"how long package status is cached between repository refreshes"`
+		ConnectTimeout     time.Duration    `yaml:"connect_timeout" env:"CONNECT_TIMEOUT" default:"5s"`
+		ReadTimeout        time.Duration    `yaml:"read_timeout" env:"READ_TIMEOUT" default:"10s"`
+		WriteTimeout       time.Duration    `yaml:"write_timeout" env:"WRITE_TIMEOUT" default:"10s"`
+		MaxHeaderMegabytes int              `yaml:"max_header_megabytes" env:"MAX_HEADER_MEGABYTES" default:"1"`
+		Cors               HTTPCorsConfig   `yaml:"cors"`
+		Auth               HTTPAuthConfig   `yaml:"auth"`
+	}
+
+	HTTPSocketConfig struct {
+		Enabled bool   `yaml:"enabled" env:"SOCKET_ENABLED" usage:"serve the API on a unix socket"`
+		Path    string `yaml:"path" env:"SOCKET_PATH" default:"/home/dv/updater/dv-updater.sock"`
+		Mode    string `yaml:"mode" env:"SOCKET_MODE" default:"0660" usage:"octal permissions of the socket"`
+		Owner   string `yaml:"owner" env:"SOCKET_OWNER" usage:"user owning the socket, the updater user by default"`
+		Group   string `yaml:"group" env:"SOCKET_GROUP" usage:"group owning the socket" example:"dv"`
+	}
+
+	HTTPTLSConfig struct {
+		Enabled      bool   `yaml:"enabled" env:"TLS_ENABLED" usage:"serve the TCP listener over TLS"`
+		CertFile     string `yaml:"cert_file" env:"TLS_CERT_FILE" validate:"required_if=Enabled true"`
+		KeyFile      string `yaml:"key_file" env:"TLS_KEY_FILE" validate:"required_if=Enabled true"`
+		MinVersion   string `yaml:"min_version" env:"TLS_MIN_VERSION" default:"1.2" validate:"oneof=1.0 1.1 1.2 1.3"`
+		ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" usage:"CA bundle, clients must present a certificate signed by it"`
+	}
+
+	HTTPAuthConfig struct {
+		Enabled      bool           `yaml:"enabled" env:"AUTH_ENABLED" default:"true" usage:"reject API requests without a token or signature"`
+		Tokens       []string       `yaml:"tokens" usage:"static bearer tokens"`
+		HMACSecret   string         `yaml:"hmac_secret" env:"AUTH_HMAC_SECRET" usage:"secret of HMAC signed requests"`
+		ReplayWindow time.Duration  `yaml:"replay_window" env:"AUTH_REPLAY_WINDOW" default:"5m" usage:"how far the signature timestamp may be from now"`
+		Keys         []APIKeyConfig `yaml:"keys" validate:"dive"`
+	}
+
+	APIKeyConfig struct {
+		ID         string   `yaml:"id" validate:"required"`
+		Token      string   `yaml:"token" validate:"required_without=HMACSecret" usage:"bearer token of the key"`
+		HMACSecret string   `yaml:"hmac_secret" usage:"secret of requests signed with the key"`
+		Scopes     []string `yaml:"scopes" validate:"required" usage:"allowed operations" example:"read:versions, update:dv-merchant, update:self, rollback"`
 	}
 
 	SeedConfig struct {
@@ -39,6 +80,73 @@ type (
 	}
 
 	AutoUpdateConfig struct {
-		Enabled bool `yaml:"enabled" default:"true"`
+		Enabled  bool                     `yaml:"enabled" default:"true"`
+		Policies []AutoUpdatePolicyConfig `yaml:"policies" validate:"dive"`
+	}
+
+	AutoUpdatePolicyConfig struct {
+		Package  string        `yaml:"package" validate:"required"`
+		Window   string        `yaml:"window" validate:"required" usage:"cron expression of the maintenance window start" example:"0 3 * * *"`
+		Duration time.Duration `yaml:"duration" validate:"gte=0" usage:"how long the maintenance window stays open, 1h when empty" example:"2h"`
+		Channel  string        `yaml:"channel" validate:"omitempty,oneof=patch minor any" usage:"which version bumps may be installed" example:"patch / minor / any"`
+		Delay    time.Duration `yaml:"delay" usage:"how long a version must be known before it is installed" example:"24h"`
+	}
+
+	PackageConfig struct {
+		Name        string            `yaml:"name" validate:"required"`
+		DisplayName string            `yaml:"display_name"`
+		Unit        string            `yaml:"unit"`
+		AutoUpdate  bool              `yaml:"auto_update"`
+		Channel     string            `yaml:"channel" validate:"omitempty,oneof=stable rc nightly"`
+		Health      HealthCheckConfig `yaml:"health"`
+	}
+
+	HealthCheckConfig struct {
+		Enabled bool          `yaml:"enabled" usage:"verify the package after upgrades and roll back when it is unhealthy"`
+		URL     string        `yaml:"url" usage:"HTTP probe which must answer 2xx" example:"http://localhost:8080/ping"`
+		Timeout time.Duration `yaml:"timeout" usage:"how long to wait for the package to become healthy" example:"60s"`
+	}
+
+	RepositoryConfig struct {
+		AptURL     string `yaml:"apt_url" env:"APT_URL" usage:"dvnet apt repository, release channels are its suites. Enables channel switching"`
+		AptOptions string `yaml:"apt_options" env:"APT_OPTIONS" usage:"options of the apt source entry" example:"[signed-by=/usr/share/keyrings/dvnet.gpg]"`
+		YumURL     string `yaml:"yum_url" env:"YUM_URL" usage:"dvnet rpm repository of yum, dnf and zypper, {channel} is replaced with the release channel. Enables channel switching"`
+		YumGPGKey  string `yaml:"yum_gpg_key" env:"YUM_GPG_KEY" usage:"gpg key URL of the rpm repository"`
+		ApkURL     string `yaml:"apk_url" env:"APK_URL" usage:"dvnet apk repository, {channel} is replaced with the release channel. Passed to apk with --repository and enables channel switching"`
+		ApkKey     string `yaml:"apk_key" env:"APK_KEY" usage:"URL or path of the public key the apk repository is signed with, installed to /etc/apk/keys under its file name when the updater runs as root" example:"https://repo.example.com/alpine/dvnet-5f3a.rsa.pub"`
+	}
+
+	AuditConfig struct {
+		Enabled     bool `yaml:"enabled" env:"AUDIT_ENABLED" default:"true" usage:"record update operations in audit.jsonl of the data dir"`
+		MaxSizeMB   int  `yaml:"max_size_mb" env:"AUDIT_MAX_SIZE_MB" default:"50" usage:"size after which the audit log is rotated, one rotated file is kept"`
+		OutputLimit int  `yaml:"output_limit" env:"AUDIT_OUTPUT_LIMIT" default:"4096" usage:"how many trailing bytes of command output are recorded"`
+	}
+
+	WebhooksConfig struct {
+		Endpoints   []WebhookConfig `yaml:"endpoints" validate:"dive"`
+		Timeout     time.Duration   `yaml:"timeout" env:"WEBHOOK_TIMEOUT" default:"10s" usage:"timeout of a single delivery attempt"`
+		MaxAttempts int             `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" default:"5" usage:"delivery attempts before an event is dropped"`
+		RetryDelay  time.Duration   `yaml:"retry_delay" env:"WEBHOOK_RETRY_DELAY" default:"2s" usage:"delay before the first retry, doubled on every attempt"`
+		QueueSize   int             `yaml:"queue_size" env:"WEBHOOK_QUEUE_SIZE" default:"100" usage:"how many events can wait for delivery per endpoint"`
+	}
+
+	WebhookConfig struct {
+		URL    string   `yaml:"url" validate:"required,url"`
+		Secret string   `yaml:"secret" usage:"signs the payload with HMAC-SHA256"`
+		Events []string `yaml:"events" validate:"dive,oneof=update_available upgrade_started upgrade_succeeded upgrade_failed rolled_back self_update_restarting" usage:"delivered events, all when empty"`
+	}
+
+	JobsConfig struct {
+		HistoryLimit int `yaml:"history_limit" env:"HISTORY_LIMIT" default:"100" usage:"how many finished update jobs are kept in memory"`
+		QueueSize    int `yaml:"queue_size" env:"QUEUE_SIZE" default:"32" usage:"how many update jobs can wait for execution"`
 	}
 )
+
+// SetDefaults fills the managed package catalog. Defaults are set before the config is loaded,
+// a packages section in the config file replaces them.
+func (c *Config) SetDefaults() {
+	c.Packages = []PackageConfig{
+		{Name: "dv-merchant", DisplayName: "DV Merchant", Unit: "dv-merchant.service"},
+		{Name: "dv-processing", DisplayName: "DV Processing", Unit: "dv-processing.service"},
+	}
+}
@@ -29,7 +29,9 @@ func Load[T any](configPaths []string, envPrefix string) (*T, error) {
 		xconfig.WithLoader(loader),
 		xconfig.WithPlugins(
 			validate.New(func(a any) error {
-				return validator.New().Struct(a)
+				v := validator.New()
+				v.RegisterStructValidation(validateHTTP, HTTPConfig{})
+				return v.Struct(a)
 			}),
 		),
 	)
@@ -39,3 +41,17 @@ func Load[T any](configPaths []string, envPrefix string) (*T, error) {
 
 	return conf, nil
 }
+
+// validateHTTP rejects TLS settings which would not apply, TLS is served on the TCP listener only,
+// and enabled auth without credentials, which would reject every API request.
+func validateHTTP(sl validator.StructLevel) {
+	conf := sl.Current().Interface().(HTTPConfig)
+	if conf.TLS.Enabled && !conf.TCPEnabled {
+		sl.ReportError(conf.TLS.Enabled, "TLS.Enabled", "Enabled", "tls_requires_tcp", "")
+	}
+
+	auth := conf.Auth
+	if auth.Enabled && len(auth.Tokens) == 0 && len(auth.Keys) == 0 && auth.HMACSecret == "" {
+		sl.ReportError(auth.Enabled, "Auth.Enabled", "Enabled", "auth_requires_credentials", "")
+	}
+}
@@ -216,6 +216,8 @@ func BestGuess(lsbProperties ReleaseDetails, osReleaseProperties ReleaseDetails)
 var List = []func(ReleaseDetails, ReleaseDetails) (bool, LinuxDistro){
 	IsCentOS,
 	IsRHEL,
+	IsRockyLinux,
+	IsAlmaLinux,
 	IsUbuntu,
 	IsDebian,
 	IsAmazonLinux,
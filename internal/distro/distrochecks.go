@@ -6,6 +6,34 @@ import (
 	"strings"
 )
 
+func IsAlmaLinux(lsbProperties ReleaseDetails, osReleaseProperties ReleaseDetails) (bool, LinuxDistro) {
+	if osReleaseProperties["ID"] == "almalinux" {
+		return true, LinuxDistro{
+			Name:       "AlmaLinux",
+			ID:         "almalinux",
+			Version:    osReleaseProperties["VERSION_ID"],
+			LsbRelease: lsbProperties,
+			OsRelease:  osReleaseProperties,
+		}
+	}
+
+	exists, contents := readFileFunc("/etc/almalinux-release")
+	if exists {
+		matched, version := parseRedhatReleaseContents(contents, "AlmaLinux")
+		if matched {
+			return true, LinuxDistro{
+				Name:       "AlmaLinux",
+				ID:         "almalinux",
+				Version:    version,
+				LsbRelease: lsbProperties,
+				OsRelease:  osReleaseProperties,
+			}
+		}
+	}
+
+	return false, LinuxDistro{}
+}
+
 func IsAlpine(lsbProperties ReleaseDetails, osReleaseProperties ReleaseDetails) (bool, LinuxDistro) {
 	if osReleaseProperties["ID"] == "alpine" {
 		return true, LinuxDistro{
@@ -531,6 +559,34 @@ func IsRHEL(lsbProperties ReleaseDetails, osReleaseProperties ReleaseDetails) (b
 	return false, LinuxDistro{}
 }
 
+func IsRockyLinux(lsbProperties ReleaseDetails, osReleaseProperties ReleaseDetails) (bool, LinuxDistro) {
+	if osReleaseProperties["ID"] == "rocky" {
+		return true, LinuxDistro{
+			Name:       "Rocky Linux",
+			ID:         "rocky",
+			Version:    osReleaseProperties["VERSION_ID"],
+			LsbRelease: lsbProperties,
+			OsRelease:  osReleaseProperties,
+		}
+	}
+
+	exists, contents := readFileFunc("/etc/rocky-release")
+	if exists {
+		matched, version := parseRedhatReleaseContents(contents, "Rocky Linux")
+		if matched {
+			return true, LinuxDistro{
+				Name:       "Rocky Linux",
+				ID:         "rocky",
+				Version:    version,
+				LsbRelease: lsbProperties,
+				OsRelease:  osReleaseProperties,
+			}
+		}
+	}
+
+	return false, LinuxDistro{}
+}
+
 func IsSLES(lsbProperties ReleaseDetails, osReleaseProperties ReleaseDetails) (bool, LinuxDistro) {
 	if osReleaseProperties["ID"] == "sles" {
 		return true, LinuxDistro{
@@ -0,0 +1,57 @@
+package distro
+
+import "strings"
+
+// Family groups distributions sharing a package manager.
+type Family string
+
+const (
+	FamilyUnknown Family = ""
+	FamilyDebian  Family = "debian"
+	FamilyRHEL    Family = "rhel"
+	FamilySUSE    Family = "suse"
+	FamilyAlpine  Family = "alpine"
+)
+
+var families = map[string]Family{
+	"debian":    FamilyDebian,
+	"ubuntu":    FamilyDebian,
+	"linuxmint": FamilyDebian,
+	"kali":      FamilyDebian,
+	"mx":        FamilyDebian,
+
+	"rhel":       FamilyRHEL,
+	"centos":     FamilyRHEL,
+	"fedora":     FamilyRHEL,
+	"rocky":      FamilyRHEL,
+	"almalinux":  FamilyRHEL,
+	"ol":         FamilyRHEL,
+	"amzn":       FamilyRHEL,
+	"scientific": FamilyRHEL,
+
+	"opensuse": FamilySUSE,
+	"sles":     FamilySUSE,
+	"suse":     FamilySUSE,
+
+	"alpine": FamilyAlpine,
+}
+
+// Family returns the family of the distribution. Derivatives unknown to the detection are
+// recognized by the ID_LIKE field of os-release, e.g. "ID_LIKE=ubuntu debian" of Pop!_OS.
+func (d LinuxDistro) Family() Family {
+	if f, ok := families[d.ID]; ok {
+		return f
+	}
+
+	if f, ok := families[d.OsRelease["ID"]]; ok {
+		return f
+	}
+
+	for _, id := range strings.Fields(d.OsRelease["ID_LIKE"]) {
+		if f, ok := families[id]; ok {
+			return f
+		}
+	}
+
+	return FamilyUnknown
+}
@@ -1,15 +1,24 @@
 package handler
 
 import (
+	"context"
 	"errors"
+	"io"
+	"time"
 
+	"github.com/dv-net/dv-updater/internal/http/middleware"
 	"github.com/dv-net/dv-updater/internal/http/request"
 	"github.com/dv-net/dv-updater/internal/http/response"
 	"github.com/dv-net/dv-updater/internal/service"
+	"github.com/dv-net/dv-updater/internal/service/audit"
+	"github.com/dv-net/dv-updater/internal/service/coordinator"
+	"github.com/dv-net/dv-updater/internal/service/job"
 	"github.com/dv-net/dv-updater/internal/service/package_manager"
+	"github.com/dv-net/dv-updater/internal/service/updater"
 	"github.com/dv-net/dv-updater/pkg/logger"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
 )
 
 type Handler struct {
@@ -27,9 +36,20 @@ func NewHandler(services *service.Services, logger logger.Logger) *Handler {
 func (h *Handler) Init(api *fiber.App) {
 	v1 := api.Group("api/v1")
 
+	// update checks the scope of the requested package itself
 	v1.Post("/update", h.updatePackage)
-	v1.Get("/version/:name", h.getLastVersionPackage)
-	v1.Get("/version", h.getUpdaterVersion)
+	v1.Post("/rollback", h.rollbackPackage, middleware.RequireScope(middleware.ScopeRollback))
+	v1.Get("/version/:name", h.getLastVersionPackage, middleware.RequireScope(middleware.ScopeReadVersions))
+	v1.Get("/packages", h.getPackages, middleware.RequireScope(middleware.ScopeReadVersions))
+	v1.Get("/version", h.getUpdaterVersion, middleware.RequireScope(middleware.ScopeReadVersions))
+	v1.Get("/versions/:name", h.getPackageVersions, middleware.RequireScope(middleware.ScopeReadVersions))
+	v1.Get("/jobs/:id", h.getJob, middleware.RequireScope(middleware.ScopeReadJobs))
+	v1.Get("/jobs/:id/stream", h.streamJob, middleware.RequireScope(middleware.ScopeReadJobs))
+	v1.Get("/operations", h.getOperations, middleware.RequireScope(middleware.ScopeReadJobs))
+	v1.Get("/audit", h.getAudit, middleware.RequireScope(middleware.ScopeReadAudit))
+	v1.Get("/schedule", h.getSchedule, middleware.RequireScope(middleware.ScopeReadSchedule))
+	v1.Get("/channel", h.getChannels, middleware.RequireScope(middleware.ScopeReadChannels))
+	v1.Put("/channel", h.setChannel, middleware.RequireScope(middleware.ScopeWriteChannels))
 }
 
 func (h *Handler) updatePackage(c fiber.Ctx) error {
@@ -38,12 +58,102 @@ func (h *Handler) updatePackage(c fiber.Ctx) error {
 		return err
 	}
 
-	err := h.services.PackageManager.UpgradePackage(c.Context(), req.Name)
+	if err := h.services.CatalogService.Validate(req.Name); err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, "name is invalid"))
+	}
+
+	scope := middleware.ScopeUpdate(req.Name)
+	if req.Name == service.DVUpdaterServiceName {
+		scope = middleware.ScopeUpdateSelf
+	}
+	if !middleware.HasScope(c, scope) {
+		return middleware.Forbidden(c, scope)
+	}
+
+	if req.DryRun {
+		return h.simulateUpdate(c, req)
+	}
+
+	// operators wait for their updates, self-updates restart the updater and go last
+	priority := coordinator.PriorityHigh
+	if req.Name == service.DVUpdaterServiceName {
+		priority = coordinator.PriorityLow
+	}
+
+	requester := requesterOf(c)
+	updateJob, err := h.services.JobService.Enqueue(job.OperationUpdate, req.Name, priority, func(ctx context.Context, output io.Writer) (updater.Result, error) {
+		ctx = audit.WithRequester(ctx, requester)
+		if req.Version != "" {
+			return h.services.UpdaterService.Install(ctx, req.Name, req.Version, output)
+		}
+		return h.services.UpdaterService.Upgrade(ctx, req.Name, output)
+	})
+	if err != nil {
+		if errors.Is(err, job.ErrQueueFull) {
+			return c.JSON(response.Fail(fiber.StatusServiceUnavailable, err.Error()))
+		}
+		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(response.OkByData(updateJob))
+}
+
+func (h *Handler) simulateUpdate(c fiber.Ctx, req *request.UpdatePackageRequest) error {
+	plan, err := h.services.PackageManager.SimulateUpgrade(c.Context(), req.Name, req.Version)
+	if err != nil {
+		if errors.Is(err, package_manager.ErrVersionNotFound) {
+			return c.JSON(response.Fail(fiber.StatusNotFound, err.Error()))
+		}
+		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(response.OkByData(plan))
+}
+
+func (h *Handler) rollbackPackage(c fiber.Ctx) error {
+	req := new(request.RollbackPackageRequest)
+	if err := c.Bind().Body(req); err != nil {
+		return err
+	}
+
+	if err := h.services.CatalogService.Validate(req.Name); err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, "name is invalid"))
+	}
+
+	requester := requesterOf(c)
+	rollbackJob, err := h.services.JobService.Enqueue(job.OperationRollback, req.Name, coordinator.PriorityHigh, func(ctx context.Context, output io.Writer) (updater.Result, error) {
+		ctx = audit.WithRequester(ctx, requester)
+		return h.services.UpdaterService.Rollback(ctx, req.Name, output)
+	})
+	if err != nil {
+		if errors.Is(err, job.ErrQueueFull) {
+			return c.JSON(response.Fail(fiber.StatusServiceUnavailable, err.Error()))
+		}
+		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(response.OkByData(rollbackJob))
+}
+
+func (h *Handler) getJob(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, "id is invalid"))
+	}
+
+	updateJob, err := h.services.JobService.Get(id)
 	if err != nil {
+		if errors.Is(err, job.ErrJobNotFound) {
+			return c.JSON(response.Fail(fiber.StatusNotFound, err.Error()))
+		}
 		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
 	}
 
-	return c.JSON(response.OkByMessage("Success update package"))
+	return c.JSON(response.OkByData(updateJob))
+}
+
+func (h *Handler) getOperations(c fiber.Ctx) error {
+	return c.JSON(response.OkByData(h.services.CoordinatorService.List()))
 }
 
 func (h *Handler) getLastVersionPackage(c fiber.Ctx) error {
@@ -52,17 +162,114 @@ func (h *Handler) getLastVersionPackage(c fiber.Ctx) error {
 		return c.JSON(response.Fail(fiber.StatusBadRequest, "name is empty"))
 	}
 
-	if err := service.ValidateServiceName(name); err != nil {
+	if err := h.services.CatalogService.Validate(name); err != nil {
 		return c.JSON(response.Fail(fiber.StatusBadRequest, "name is invalid"))
 	}
 
-	pkg, err := h.services.PackageManager.CheckForUpdates(c.Context(), name)
+	pkg, err := h.services.StatusService.Package(c.Context(), name)
 	if err != nil && !errors.Is(err, package_manager.ErrNothingToUpdate) {
 		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
 	}
 	return c.JSON(response.OkByData(pkg))
 }
 
+func (h *Handler) getPackages(c fiber.Ctx) error {
+	return c.JSON(response.OkByData(h.services.StatusService.Snapshot(c.Context())))
+}
+
+func (h *Handler) getPackageVersions(c fiber.Ctx) error {
+	name := c.Params("name")
+	if err := h.services.CatalogService.Validate(name); err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, "name is invalid"))
+	}
+
+	versions, err := h.services.PackageManager.ListVersions(c.Context(), name)
+	if err != nil {
+		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(response.OkByData(versions))
+}
+
 func (h *Handler) getUpdaterVersion(c fiber.Ctx) error {
 	return c.JSON(response.OkByData(h.services.SystemInfoService.GetSystemInfo()))
 }
+
+func (h *Handler) getAudit(c fiber.Ctx) error {
+	req := new(request.AuditRequest)
+	if err := c.Bind().Query(req); err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, err.Error()))
+	}
+
+	filter := audit.Filter{Package: req.Package, Limit: req.Limit}
+	var err error
+	if req.From != "" {
+		if filter.From, err = time.Parse(time.RFC3339, req.From); err != nil {
+			return c.JSON(response.Fail(fiber.StatusBadRequest, "from is invalid"))
+		}
+	}
+	if req.To != "" {
+		if filter.To, err = time.Parse(time.RFC3339, req.To); err != nil {
+			return c.JSON(response.Fail(fiber.StatusBadRequest, "to is invalid"))
+		}
+	}
+
+	records, err := h.services.AuditService.List(filter)
+	if err != nil {
+		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(response.OkByData(records))
+}
+
+func (h *Handler) getSchedule(c fiber.Ctx) error {
+	return c.JSON(response.OkByData(h.services.SchedulerService.Plans()))
+}
+
+func (h *Handler) getChannels(c fiber.Ctx) error {
+	return c.JSON(response.OkByData(h.services.ChannelService.List()))
+}
+
+func (h *Handler) setChannel(c fiber.Ctx) error {
+	req := new(request.SetChannelRequest)
+	if err := c.Bind().Body(req); err != nil {
+		return err
+	}
+
+	if err := h.services.CatalogService.Validate(req.Name); err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, "name is invalid"))
+	}
+
+	channel := package_manager.Channel(req.Channel)
+	if err := channel.Validate(); err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, err.Error()))
+	}
+
+	// switches are requested by operators, the metadata refresh may outlast the request
+	switchJob, err := h.services.JobService.Enqueue(job.OperationChannelSwitch, req.Name, coordinator.PriorityHigh, func(ctx context.Context, output io.Writer) (updater.Result, error) {
+		if err := h.services.ChannelService.Set(ctx, req.Name, channel); err != nil {
+			return updater.Result{}, err
+		}
+
+		// the repositories were refreshed, available versions may change for every package
+		h.services.StatusService.Invalidate()
+		return updater.Result{}, nil
+	})
+	if err != nil {
+		if errors.Is(err, job.ErrQueueFull) {
+			return c.JSON(response.Fail(fiber.StatusServiceUnavailable, err.Error()))
+		}
+		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(response.OkByData(switchJob))
+}
+
+// requesterOf returns who the request is made by for the audit log.
+func requesterOf(c fiber.Ctx) string {
+	if key := middleware.KeyFrom(c); key != nil {
+		return key.ID
+	}
+
+	return "api"
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/http/response"
+	"github.com/dv-net/dv-updater/internal/service/job"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+const (
+	streamHeartbeat    = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamJob sends the job output line by line as server-sent events until the job finishes:
+//
+//	output: a line of apt/yum output, the event id is the output offset after it
+//	state:  the job without output whenever it starts running
+//	done:   the finished job without output, the stream is closed afterwards
+//
+// Reconnecting clients resume after the Last-Event-ID offset.
+func (h *Handler) streamJob(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.JSON(response.Fail(fiber.StatusBadRequest, "id is invalid"))
+	}
+
+	offset, _ := strconv.ParseInt(c.Get("Last-Event-ID"), 10, 64)
+	first, err := h.services.JobService.Follow(id, offset)
+	if err != nil {
+		if errors.Is(err, job.ErrJobNotFound) {
+			return c.JSON(response.Fail(fiber.StatusNotFound, err.Error()))
+		}
+		return c.JSON(response.Fail(fiber.StatusInternalServerError, err.Error()))
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// the server write timeout covers the whole response, the deadline is extended on every flush instead
+	conn := c.RequestCtx().Conn()
+
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		s := &jobStream{w: w, conn: conn}
+		upd := first
+		for {
+			s.output(upd)
+			if upd.Job.Finished() {
+				s.flushPending()
+				s.event("done", "", upd.Job)
+				_ = s.flush()
+				return
+			}
+
+			if upd.Job.State != s.state {
+				s.state = upd.Job.State
+				s.event("state", "", upd.Job)
+			}
+
+			if s.flush() != nil {
+				return
+			}
+
+			select {
+			case <-upd.Changed:
+			case <-time.After(streamHeartbeat):
+				_, _ = w.WriteString(": keepalive\n\n")
+			}
+
+			if upd, err = h.services.JobService.Follow(id, upd.Offset); err != nil {
+				s.event("error", "", err.Error())
+				_ = s.flush()
+				return
+			}
+		}
+	})
+}
+
+type jobStream struct {
+	w       *bufio.Writer
+	conn    net.Conn
+	state   job.State
+	pending []byte
+	offset  int64
+}
+
+// output emits every complete line, a trailing partial line waits for the rest.
+func (s *jobStream) output(upd job.Update) {
+	data := append(s.pending, upd.Output...)
+	s.offset = upd.Offset - int64(len(data))
+
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+
+		s.offset += int64(i + 1)
+		s.event("output", strconv.FormatInt(s.offset, 10), string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+		data = data[i+1:]
+	}
+
+	s.pending = append(s.pending[:0], data...)
+}
+
+func (s *jobStream) flushPending() {
+	if len(s.pending) == 0 {
+		return
+	}
+
+	s.offset += int64(len(s.pending))
+	s.event("output", strconv.FormatInt(s.offset, 10), string(s.pending))
+	s.pending = nil
+}
+
+func (s *jobStream) event(name, id string, data any) {
+	if id != "" {
+		_, _ = fmt.Fprintf(s.w, "id: %s\n", id)
+	}
+
+	payload, ok := data.(string)
+	if !ok {
+		encoded, _ := json.Marshal(data)
+		payload = string(encoded)
+	}
+
+	_, _ = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, payload)
+}
+
+func (s *jobStream) flush() error {
+	if s.conn != nil {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	}
+
+	return s.w.Flush()
+}
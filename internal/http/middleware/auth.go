@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/http/response"
+	"github.com/dv-net/dv-updater/pkg/logger"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	HeaderKeyID     = "X-Key-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+
+	bearerPrefix = "Bearer "
+	localKey     = "api_key"
+)
+
+// Auth rejects API requests which carry neither a known bearer token nor a valid HMAC signature.
+//
+// Signed requests send the unix time in X-Timestamp and the hex encoded HMAC-SHA256 of
+// "<timestamp>\n<method>\n<path>\n<body>" in X-Signature. The timestamp must be within the replay
+// window and every signature is accepted only once. X-Key-Id selects the secret of an API key,
+// without it the shared HMAC secret is used.
+type Auth struct {
+	conf   config.HTTPAuthConfig
+	logger logger.Logger
+	keys   []*Key
+	shared *Key
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewAuth(conf config.HTTPAuthConfig, l logger.Logger) *Auth {
+	a := &Auth{
+		conf:   conf,
+		logger: l,
+		seen:   make(map[string]time.Time),
+	}
+
+	// tokens and the shared secret predate API keys and keep full access
+	for i, token := range conf.Tokens {
+		a.keys = append(a.keys, &Key{ID: "token-" + strconv.Itoa(i+1), token: token, scopes: []string{ScopeAll}})
+	}
+	if conf.HMACSecret != "" {
+		a.shared = &Key{ID: "hmac", secret: conf.HMACSecret, scopes: []string{ScopeAll}}
+	}
+	for _, k := range conf.Keys {
+		a.keys = append(a.keys, &Key{ID: k.ID, token: k.Token, secret: k.HMACSecret, scopes: k.Scopes})
+	}
+
+	if conf.Enabled && len(a.keys) == 0 && a.shared == nil {
+		l.Warn("API authentication is enabled without API keys, tokens or HMAC secret, all API requests are rejected")
+	}
+
+	return a
+}
+
+func (a *Auth) Handler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !a.conf.Enabled {
+			return c.Next()
+		}
+
+		var key *Key
+		if token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), bearerPrefix); ok {
+			if key = a.keyByToken(token); key == nil {
+				return a.reject(c, "invalid token")
+			}
+		} else if c.Get(HeaderSignature) != "" {
+			var reason string
+			if key, reason = a.verifySignature(c); key == nil {
+				return a.reject(c, reason)
+			}
+		} else {
+			return a.reject(c, "authentication required")
+		}
+
+		if c.Method() == fiber.MethodGet {
+			a.logger.Debug("API key used", "key_id", key.ID, "method", c.Method(), "path", c.Path())
+		} else {
+			a.logger.Info("API key used", "key_id", key.ID, "method", c.Method(), "path", c.Path(), "ip", c.IP())
+		}
+
+		c.Locals(localKey, key)
+		return c.Next()
+	}
+}
+
+func (a *Auth) keyByToken(token string) *Key {
+	var found *Key
+	for _, k := range a.keys {
+		if k.token != "" && subtle.ConstantTimeCompare([]byte(k.token), []byte(token)) == 1 {
+			found = k
+		}
+	}
+
+	return found
+}
+
+func (a *Auth) keyByID(id string) *Key {
+	if id == "" {
+		return a.shared
+	}
+
+	for _, k := range a.keys {
+		if k.ID == id && k.secret != "" {
+			return k
+		}
+	}
+
+	return nil
+}
+
+// verifySignature returns the key which signed the request or the reason the signature is rejected.
+func (a *Auth) verifySignature(c fiber.Ctx) (*Key, string) {
+	key := a.keyByID(c.Get(HeaderKeyID))
+	if key == nil {
+		return nil, "signed requests are not accepted for this key"
+	}
+
+	ts, err := strconv.ParseInt(c.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, "invalid timestamp"
+	}
+
+	now := time.Now()
+	signedAt := time.Unix(ts, 0)
+	if signedAt.Before(now.Add(-a.conf.ReplayWindow)) || signedAt.After(now.Add(a.conf.ReplayWindow)) {
+		return nil, "timestamp is outside of the replay window"
+	}
+
+	signature, err := hex.DecodeString(c.Get(HeaderSignature))
+	if err != nil || !hmac.Equal(signature, Sign(key.secret, ts, c.Method(), c.OriginalURL(), c.Body())) {
+		return nil, "invalid signature"
+	}
+
+	if !a.remember(hex.EncodeToString(signature), signedAt.Add(a.conf.ReplayWindow), now) {
+		return nil, "signature was already used"
+	}
+
+	return key, ""
+}
+
+// remember records the signature until it expires, it returns false for signatures seen before.
+func (a *Auth) remember(signature string, expiresAt, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for s, exp := range a.seen {
+		if now.After(exp) {
+			delete(a.seen, s)
+		}
+	}
+
+	if _, ok := a.seen[signature]; ok {
+		return false
+	}
+
+	a.seen[signature] = expiresAt
+	return true
+}
+
+func (a *Auth) reject(c fiber.Ctx, reason string) error {
+	a.logger.Warn("API request rejected", "reason", reason, "method", c.Method(), "path", c.Path(), "ip", c.IP())
+	return c.Status(fiber.StatusUnauthorized).JSON(response.Fail(fiber.StatusUnauthorized, reason))
+}
+
+// Sign returns the HMAC-SHA256 signature of a request. uri is the path including the query string.
+func Sign(secret string, timestamp int64, method, uri string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "\n" + method + "\n" + uri + "\n"))
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/dv-net/dv-updater/internal/http/response"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	ScopeAll           = "*"
+	ScopeReadVersions  = "read:versions"
+	ScopeReadJobs      = "read:jobs"
+	ScopeReadSchedule  = "read:schedule"
+	ScopeReadAudit     = "read:audit"
+	ScopeReadMetrics   = "read:metrics"
+	ScopeReadChannels  = "read:channels"
+	ScopeWriteChannels = "write:channels"
+	ScopeRollback      = "rollback"
+	ScopeUpdateSelf    = "update:self"
+)
+
+// Key is the API key which authenticated the request.
+type Key struct {
+	ID     string
+	token  string
+	secret string
+	scopes []string
+}
+
+// Allows reports whether the key grants the scope. "*" grants everything, "update:*" every update scope.
+func (k *Key) Allows(scope string) bool {
+	for _, s := range k.scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(s, "*"); ok && strings.HasPrefix(scope, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScopeUpdate returns the scope required to update the package.
+func ScopeUpdate(packageName string) string {
+	return "update:" + packageName
+}
+
+// KeyFrom returns the API key of the request, nil when authentication is disabled.
+func KeyFrom(c fiber.Ctx) *Key {
+	key, _ := c.Locals(localKey).(*Key)
+	return key
+}
+
+// HasScope reports whether the request may use the scope. Everything is allowed when authentication is disabled.
+func HasScope(c fiber.Ctx, scope string) bool {
+	key := KeyFrom(c)
+	return key == nil || key.Allows(scope)
+}
+
+// RequireScope rejects requests whose API key does not grant the scope.
+func RequireScope(scope string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !HasScope(c, scope) {
+			return Forbidden(c, scope)
+		}
+
+		return c.Next()
+	}
+}
+
+// Forbidden responds that the API key lacks the scope.
+func Forbidden(c fiber.Ctx, scope string) error {
+	return c.Status(fiber.StatusForbidden).JSON(response.Fail(fiber.StatusForbidden, "API key has no "+scope+" scope"))
+}
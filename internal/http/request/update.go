@@ -1,5 +1,23 @@
 package request
 
 type UpdatePackageRequest struct {
-	Name string `json:"name" validate:"required,oneof=dv-processing dv-merchant"`
+	Name    string `json:"name" validate:"required"`
+	Version string `json:"version,omitempty"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+}
+
+type RollbackPackageRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type SetChannelRequest struct {
+	Name    string `json:"name" validate:"required"`
+	Channel string `json:"channel" validate:"required,oneof=stable rc nightly"`
+}
+
+type AuditRequest struct {
+	Package string `query:"package"`
+	From    string `query:"from"`
+	To      string `query:"to"`
+	Limit   int    `query:"limit" validate:"omitempty,min=1"`
 }
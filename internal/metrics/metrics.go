@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "dv_updater"
+
+var (
+	upgrades = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upgrades_total",
+		Help:      "Package operations by package, operation and result.",
+	}, []string{"package", "operation", "result"})
+
+	upgradeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upgrade_duration_seconds",
+		Help:      "Duration of package operations.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"package", "operation"})
+
+	repositoryRefreshDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_refresh_duration_seconds",
+		Help:      "Duration of package repository refreshes.",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 30, 60},
+	})
+
+	repositoryRefreshFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "repository_refresh_failures_total",
+		Help:      "Failed package repository refreshes.",
+	})
+
+	dpkgLockWaits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dpkg_lock_waits_total",
+		Help:      "Times an apt command waited for the dpkg lock.",
+	})
+
+	retryAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retry_attempts_total",
+		Help:      "Failed attempts which were retried, by operation.",
+	}, []string{"operation"})
+
+	selfUpdateChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "self_update_checks_total",
+		Help:      "Self-update checks by result.",
+	}, []string{"result"})
+
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook deliveries by event and result.",
+	}, []string{"event", "result"})
+
+	packageInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "package_info",
+		Help:      "Installed and available version of a managed package, always 1.",
+	}, []string{"package", "installed_version", "available_version"})
+
+	packageUpdateAvailable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "package_update_available",
+		Help:      "Whether a newer version of a managed package is available.",
+	}, []string{"package"})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Self-update check results.
+const (
+	SelfUpdateUpToDate   = "up_to_date"
+	SelfUpdateAvailable  = "update_available"
+	SelfUpdatePreRelease = "skipped_pre_release"
+	SelfUpdateError      = "error"
+)
+
+func ObserveUpgrade(packageName, operation string, started time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	upgrades.WithLabelValues(packageName, operation, result).Inc()
+	upgradeDuration.WithLabelValues(packageName, operation).Observe(time.Since(started).Seconds())
+}
+
+func ObserveRepositoryRefresh(started time.Time, err error) {
+	repositoryRefreshDuration.Observe(time.Since(started).Seconds())
+	if err != nil {
+		repositoryRefreshFailures.Inc()
+	}
+}
+
+func DpkgLockWait() {
+	dpkgLockWaits.Inc()
+}
+
+// RetryHook returns a retry.WithOnRetry callback counting retried attempts of the operation.
+func RetryHook(operation string) func(attempt int, err error) {
+	return func(int, error) {
+		retryAttempts.WithLabelValues(operation).Inc()
+	}
+}
+
+func SelfUpdateCheck(result string) {
+	selfUpdateChecks.WithLabelValues(result).Inc()
+}
+
+func WebhookDelivery(event string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	webhookDeliveries.WithLabelValues(event, result).Inc()
+}
+
+// SetPackageVersions replaces the version info of the package.
+func SetPackageVersions(packageName, installed, available string, needForUpdate bool) {
+	packageInfo.DeletePartialMatch(prometheus.Labels{"package": packageName})
+	packageInfo.WithLabelValues(packageName, installed, available).Set(1)
+
+	value := 0.0
+	if needForUpdate {
+		value = 1
+	}
+	packageUpdateAvailable.WithLabelValues(packageName).Set(value)
+}
+
+// HTTP counts requests and their duration by the matched route.
+func HTTP() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		started := time.Now()
+		err := c.Next()
+
+		route := c.Route().Path
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+
+		httpRequests.WithLabelValues(c.Method(), route, strconv.Itoa(status)).Inc()
+		httpRequestDuration.WithLabelValues(c.Method(), route).Observe(time.Since(started).Seconds())
+
+		return err
+	}
+}
@@ -1,14 +1,20 @@
 package router
 
 import (
+	"strings"
+
 	"github.com/dv-net/dv-updater/internal/config"
 	"github.com/dv-net/dv-updater/internal/http/handler"
+	"github.com/dv-net/dv-updater/internal/http/middleware"
+	"github.com/dv-net/dv-updater/internal/metrics"
 	"github.com/dv-net/dv-updater/internal/service"
 	"github.com/dv-net/dv-updater/pkg/logger"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
 	"github.com/gofiber/fiber/v3/middleware/cors"
 	"github.com/gofiber/fiber/v3/middleware/etag"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Router struct {
@@ -26,7 +32,13 @@ func NewRouter(conf config.HTTPConfig, services *service.Services, logger logger
 }
 
 func (r *Router) Init(app *fiber.App) {
-	app.Use(etag.New())
+	app.Use(metrics.HTTP())
+	app.Use(etag.New(etag.Config{
+		// reading the body of a streamed response waits for the stream to end
+		Next: func(c fiber.Ctx) bool {
+			return strings.HasSuffix(c.Path(), "/stream")
+		},
+	}))
 
 	if r.config.Cors.Enabled {
 		corsConfig := cors.ConfigDefault
@@ -50,10 +62,16 @@ func (r *Router) Init(app *fiber.App) {
 	app.Get("/ping", func(c fiber.Ctx) error {
 		return c.SendString("pong")
 	})
-	r.initAPI(app)
+
+	auth := middleware.NewAuth(r.config.Auth, r.logger).Handler()
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()), auth, middleware.RequireScope(middleware.ScopeReadMetrics))
+
+	r.initAPI(app, auth)
 }
 
-func (r *Router) initAPI(app *fiber.App) {
+func (r *Router) initAPI(app *fiber.App, auth fiber.Handler) {
+	app.Use("/api", auth)
+
 	handlerV1 := handler.NewHandler(r.services, r.logger)
 	handlerV1.Init(app)
 }
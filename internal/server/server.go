@@ -1,6 +1,12 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+
 	"github.com/dv-net/dv-updater/internal/config"
 	"github.com/dv-net/dv-updater/internal/router"
 	"github.com/dv-net/dv-updater/internal/service"
@@ -9,6 +15,8 @@ import (
 	"github.com/gofiber/fiber/v3"
 )
 
+var ErrNoListeners = errors.New("neither TCP nor unix socket listener is enabled")
+
 type Server struct {
 	app    *fiber.App
 	cfg    config.HTTPConfig
@@ -30,10 +38,74 @@ func NewServer(cfg config.HTTPConfig, services *service.Services, logger logger.
 	}
 }
 
+// Run serves the API on the TCP address and the unix socket, whichever are enabled, until one of them fails.
+// TLS applies to the TCP listener only.
 func (s *Server) Run() error {
-	return s.app.Listen(":"+s.cfg.Port, fiber.ListenConfig{
-		DisableStartupMessage: true,
-	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var listeners []net.Listener
+	if s.cfg.TCPEnabled {
+		ln, err := s.listenTCP(ctx)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, ln)
+	}
+
+	if s.cfg.Socket.Enabled {
+		ln, err := listenSocket(s.cfg.Socket)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, ln)
+	}
+
+	if len(listeners) == 0 {
+		return ErrNoListeners
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		s.logger.Info("HTTP server listening", "network", ln.Addr().Network(), "addr", ln.Addr().String())
+		go func() {
+			errCh <- s.app.Listener(ln, fiber.ListenConfig{
+				DisableStartupMessage: true,
+			})
+		}()
+	}
+
+	return <-errCh
+}
+
+func (s *Server) listenTCP(ctx context.Context) (net.Listener, error) {
+	var tlsConfig *tls.Config
+	if s.cfg.TLS.Enabled {
+		reloader, err := newCertReloader(s.cfg.TLS, s.logger)
+		if err != nil {
+			return nil, err
+		}
+
+		if tlsConfig, err = reloader.TLSConfig(); err != nil {
+			return nil, err
+		}
+
+		go reloader.Watch(ctx)
+	}
+
+	ln, err := net.Listen("tcp", net.JoinHostPort(s.cfg.Host, s.cfg.Port))
+	if err != nil {
+		return nil, fmt.Errorf("listen tcp: %w", err)
+	}
+
+	if tlsConfig != nil {
+		return tls.NewListener(ln, tlsConfig), nil
+	}
+
+	return ln, nil
 }
 
 func (s *Server) Stop() error {
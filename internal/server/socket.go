@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/dv-net/dv-updater/internal/config"
+)
+
+// listenSocket creates the unix socket and applies the configured permissions and ownership.
+func listenSocket(cfg config.HTTPSocketConfig) (net.Listener, error) {
+	mode, err := strconv.ParseUint(cfg.Mode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket mode %q: %w", cfg.Mode, err)
+	}
+
+	uid, gid, err := socketOwner(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// a socket left by a previous run prevents listening
+	if info, err := os.Lstat(cfg.Path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", cfg.Path)
+		}
+		if err = os.Remove(cfg.Path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("stat socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("listen unix: %w", err)
+	}
+
+	if err = os.Chmod(cfg.Path, fs.FileMode(mode)); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+
+	if uid >= 0 || gid >= 0 {
+		if err = os.Chown(cfg.Path, uid, gid); err != nil {
+			_ = ln.Close()
+			return nil, fmt.Errorf("chown socket: %w", err)
+		}
+	}
+
+	return ln, nil
+}
+
+// socketOwner resolves the configured owner and group, -1 keeps the current one.
+func socketOwner(cfg config.HTTPSocketConfig) (int, int, error) {
+	uid, gid := -1, -1
+	if cfg.Owner != "" {
+		u, err := user.Lookup(cfg.Owner)
+		if err != nil {
+			return 0, 0, fmt.Errorf("socket owner: %w", err)
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return 0, 0, fmt.Errorf("socket owner: %w", err)
+		}
+	}
+
+	if cfg.Group != "" {
+		g, err := user.LookupGroup(cfg.Group)
+		if err != nil {
+			return 0, 0, fmt.Errorf("socket group: %w", err)
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return 0, 0, fmt.Errorf("socket group: %w", err)
+		}
+	}
+
+	return uid, gid, nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/pkg/logger"
+)
+
+const tlsWatchInterval = 10 * time.Second
+
+var ErrNoClientCA = errors.New("client CA bundle contains no certificates")
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// certReloader serves the certificate and the client CA bundle from disk. Both are reloaded on SIGHUP
+// and when one of the files changes, a failed reload keeps the previous ones.
+type certReloader struct {
+	cfg    config.HTTPTLSConfig
+	logger logger.Logger
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+func newCertReloader(cfg config.HTTPTLSConfig, l logger.Logger) (*certReloader, error) {
+	r := &certReloader{cfg: cfg, logger: l}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the server configuration, the certificate and client CAs are looked up per handshake.
+func (r *certReloader) TLSConfig() (*tls.Config, error) {
+	minVersion, ok := tlsVersions[r.cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q", r.cfg.MinVersion)
+	}
+
+	base := &tls.Config{
+		MinVersion: minVersion,
+	}
+
+	if r.cfg.ClientCAFile == "" {
+		base.GetCertificate = r.getCertificate
+		return base, nil
+	}
+
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		return &tls.Config{
+			MinVersion:   minVersion,
+			Certificates: []tls.Certificate{*r.cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    r.clientCA,
+		}, nil
+	}
+
+	return base, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Watch reloads the files on SIGHUP and on changes until ctx is done.
+func (r *certReloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(tlsWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.Info("SIGHUP received, reloading TLS certificates")
+			if err := r.reload(); err != nil {
+				r.logger.Error("failed to reload TLS certificates", err)
+			}
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			r.logger.Info("TLS certificate files changed, reloading")
+			if err := r.reload(); err != nil {
+				r.logger.Error("failed to reload TLS certificates", err)
+			}
+		}
+	}
+}
+
+func (r *certReloader) reload() error {
+	modTimes := r.stat()
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+
+	var clientCA *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(filepath.Clean(r.cfg.ClientCAFile))
+		if err != nil {
+			return fmt.Errorf("read client CA bundle: %w", err)
+		}
+
+		clientCA = x509.NewCertPool()
+		if !clientCA.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%w: %s", ErrNoClientCA, r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.clientCA = clientCA
+	r.modTimes = modTimes
+
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	current := r.stat()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for path, modTime := range current {
+		if !modTime.Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *certReloader) stat() map[string]time.Time {
+	modTimes := make(map[string]time.Time, 3)
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
@@ -0,0 +1,43 @@
+package audit
+
+import "sync"
+
+// Output keeps the tail of the operation output, it explains failures best.
+type Output struct {
+	mu        sync.Mutex
+	limit     int
+	buf       []byte
+	truncated bool
+}
+
+func newOutput(limit int) *Output {
+	return &Output{limit: limit}
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.limit <= 0 {
+		return len(p), nil
+	}
+
+	o.buf = append(o.buf, p...)
+	if overflow := len(o.buf) - o.limit; overflow > 0 {
+		o.buf = append(o.buf[:0], o.buf[overflow:]...)
+		o.truncated = true
+	}
+
+	return len(p), nil
+}
+
+func (o *Output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.truncated {
+		return "...\n" + string(o.buf)
+	}
+
+	return string(o.buf)
+}
@@ -0,0 +1,19 @@
+package audit
+
+import "context"
+
+type requesterKey struct{}
+
+// WithRequester returns a context recording operations on behalf of the requester, e.g. an API key ID.
+func WithRequester(ctx context.Context, requester string) context.Context {
+	return context.WithValue(ctx, requesterKey{}, requester)
+}
+
+// RequesterFrom returns the requester stored in ctx or RequesterSystem.
+func RequesterFrom(ctx context.Context) string {
+	if requester, ok := ctx.Value(requesterKey{}).(string); ok && requester != "" {
+		return requester
+	}
+
+	return RequesterSystem
+}
@@ -0,0 +1,267 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	logFile = "audit.jsonl"
+
+	// RequesterSystem is recorded when the operation was not started on behalf of anybody.
+	RequesterSystem = "system"
+)
+
+type Operation string
+
+const (
+	OperationUpdate            Operation = "update"
+	OperationInstall           Operation = "install"
+	OperationRollback          Operation = "rollback"
+	OperationSelfUpdate        Operation = "self_update"
+	OperationRepositoryRefresh Operation = "repository_refresh"
+)
+
+type Status string
+
+const (
+	// StatusStarted is recorded before operations which may restart the updater.
+	StatusStarted   Status = "started"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+type Record struct {
+	ID            uuid.UUID `json:"id"`
+	Time          time.Time `json:"time"`
+	Operation     Operation `json:"operation"`
+	Status        Status    `json:"status"`
+	Requester     string    `json:"requester"`
+	Package       string    `json:"package,omitempty"`
+	VersionBefore string    `json:"version_before,omitempty"`
+	VersionAfter  string    `json:"version_after,omitempty"`
+	DurationMs    int64     `json:"duration_ms"`
+	ExitCode      int       `json:"exit_code"`
+	Error         string    `json:"error,omitempty"`
+	Output        string    `json:"output,omitempty"`
+}
+
+// Filter selects records, zero fields match everything.
+type Filter struct {
+	Package string
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
+
+func (f Filter) match(r Record) bool {
+	switch {
+	case f.Package != "" && r.Package != f.Package:
+		return false
+	case !f.From.IsZero() && r.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && r.Time.After(f.To):
+		return false
+	default:
+		return true
+	}
+}
+
+// Service appends operation records to a JSON lines file in the data dir. The file is rotated once it
+// grows over the size limit, one rotated file is kept.
+type Service struct {
+	conf config.AuditConfig
+	path string
+
+	mu sync.Mutex
+}
+
+func NewService(conf config.AuditConfig, dataDir string) (*Service, error) {
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, fmt.Errorf("create audit dir: %w", err)
+	}
+
+	return &Service{
+		conf: conf,
+		path: filepath.Join(dataDir, logFile),
+	}, nil
+}
+
+// Begin starts a record of the operation on behalf of the requester in ctx.
+func (s *Service) Begin(ctx context.Context, op Operation, packageName string) *Record {
+	return &Record{
+		ID:        uuid.New(),
+		Time:      time.Now(),
+		Operation: op,
+		Requester: RequesterFrom(ctx),
+		Package:   packageName,
+	}
+}
+
+// NewOutput returns a writer capturing the operation output for the record.
+func (s *Service) NewOutput() *Output {
+	return newOutput(s.conf.OutputLimit)
+}
+
+// Finish completes the record with the operation outcome and appends it.
+func (s *Service) Finish(r *Record, output *Output, err error) error {
+	r.DurationMs = time.Since(r.Time).Milliseconds()
+	r.Status = StatusSucceeded
+	r.ExitCode = 0
+	if err != nil {
+		r.Status = StatusFailed
+		r.Error = err.Error()
+		r.ExitCode = exitCode(err)
+	}
+	if output != nil {
+		r.Output = output.String()
+	}
+
+	return s.Append(*r)
+}
+
+// Append writes the record to the log.
+func (s *Service) Append(r Record) error {
+	if !s.conf.Enabled {
+		return nil
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encode audit record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err = s.rotate(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// List returns matching records from the newest to the oldest.
+func (s *Service) List(filter Filter) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0)
+	for _, path := range []string{s.path, s.rotatedPath()} {
+		found, err := s.read(path, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, found...)
+		if filter.Limit > 0 && len(records) >= filter.Limit {
+			return records[:filter.Limit], nil
+		}
+	}
+
+	return records, nil
+}
+
+// Last returns the newest record of the package.
+func (s *Service) Last(packageName string) (Record, bool, error) {
+	records, err := s.List(Filter{Package: packageName, Limit: 1})
+	if err != nil || len(records) == 0 {
+		return Record{}, false, err
+	}
+
+	return records[0], true, nil
+}
+
+// read returns the matching records of a file, the newest first.
+func (s *Service) read(path string, filter Filter) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		// a line cut by a crash must not hide the rest of the log
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if filter.match(r) {
+			records = append(records, r)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	return records, nil
+}
+
+func (s *Service) rotate() error {
+	if s.conf.MaxSizeMB <= 0 {
+		return nil
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("stat audit log: %w", err)
+	}
+
+	if info.Size() < int64(s.conf.MaxSizeMB)*1024*1024 {
+		return nil
+	}
+
+	if err = os.Rename(s.path, s.rotatedPath()); err != nil {
+		return fmt.Errorf("rotate audit log: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) rotatedPath() string {
+	return s.path + ".1"
+}
+
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
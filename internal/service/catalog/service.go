@@ -0,0 +1,63 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dv-net/dv-updater/internal/config"
+)
+
+var ErrUnknownPackage = errors.New("package is not managed")
+
+// Service is the catalog of packages managed by the updater.
+type Service struct {
+	selfPackage string
+	packages    []config.PackageConfig
+	byName      map[string]config.PackageConfig
+}
+
+func NewService(selfPackage string, packages []config.PackageConfig) *Service {
+	byName := make(map[string]config.PackageConfig, len(packages))
+	for _, pkg := range packages {
+		byName[pkg.Name] = pkg
+	}
+
+	return &Service{
+		selfPackage: selfPackage,
+		packages:    packages,
+		byName:      byName,
+	}
+}
+
+// Packages returns the managed packages in configuration order.
+func (s *Service) Packages() []config.PackageConfig {
+	return s.packages
+}
+
+// Self returns the package name of the updater itself.
+func (s *Service) Self() string {
+	return s.selfPackage
+}
+
+func (s *Service) Get(name string) (config.PackageConfig, bool) {
+	pkg, ok := s.byName[name]
+	return pkg, ok
+}
+
+// ValidateManaged accepts only packages from the catalog.
+func (s *Service) ValidateManaged(name string) error {
+	if _, ok := s.byName[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownPackage, name)
+	}
+
+	return nil
+}
+
+// Validate accepts packages from the catalog and the updater itself.
+func (s *Service) Validate(name string) error {
+	if name == s.selfPackage {
+		return nil
+	}
+
+	return s.ValidateManaged(name)
+}
@@ -0,0 +1,139 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/dv-net/dv-updater/internal/service/catalog"
+	"github.com/dv-net/dv-updater/internal/service/coordinator"
+	"github.com/dv-net/dv-updater/internal/service/package_manager"
+	"github.com/dv-net/dv-updater/pkg/jsonfile"
+	"github.com/dv-net/dv-updater/pkg/logger"
+)
+
+const channelsFile = "channels.json"
+
+type PackageChannel struct {
+	Package string                  `json:"package"`
+	Channel package_manager.Channel `json:"channel"`
+}
+
+// Service tracks the release channel every package follows. Channels set through the API
+// are persisted in the data dir and take precedence over the catalog configuration.
+type Service struct {
+	logger         logger.Logger
+	packageManager package_manager.PackageManager
+	catalog        *catalog.Service
+	coordinator    *coordinator.Service
+	selfPackage    string
+	path           string
+
+	mu        sync.RWMutex
+	overrides map[string]package_manager.Channel
+}
+
+func NewService(
+	l logger.Logger,
+	dataDir, selfPackage string,
+	catalogService *catalog.Service,
+	coordinatorService *coordinator.Service,
+	pm package_manager.PackageManager,
+) (*Service, error) {
+	s := &Service{
+		logger:         l,
+		packageManager: pm,
+		catalog:        catalogService,
+		coordinator:    coordinatorService,
+		selfPackage:    selfPackage,
+		path:           filepath.Join(dataDir, channelsFile),
+		overrides:      make(map[string]package_manager.Channel),
+	}
+
+	if err := jsonfile.Load(s.path, &s.overrides); err != nil {
+		return nil, fmt.Errorf("load channels: %w", err)
+	}
+
+	return s, nil
+}
+
+// Apply configures the package manager with the channels of all packages which do not follow stable.
+func (s *Service) Apply(ctx context.Context) error {
+	ctx, release, err := s.coordinator.Acquire(ctx, coordinator.Operation{Name: "channel_apply", Priority: coordinator.PriorityNormal})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for _, pc := range s.List() {
+		if pc.Channel == package_manager.ChannelStable {
+			continue
+		}
+
+		if err := s.packageManager.SetChannel(ctx, pc.Package, pc.Channel); err != nil {
+			return fmt.Errorf("apply %s channel for %s: %w", pc.Channel, pc.Package, err)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the channel the package follows.
+func (s *Service) Get(packageName string) package_manager.Channel {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if c, ok := s.overrides[packageName]; ok {
+		return c
+	}
+
+	if pkg, ok := s.catalog.Get(packageName); ok && pkg.Channel != "" {
+		return package_manager.Channel(pkg.Channel)
+	}
+
+	return package_manager.ChannelStable
+}
+
+// List returns channels of the updater and every managed package.
+func (s *Service) List() []PackageChannel {
+	packages := s.catalog.Packages()
+	channels := make([]PackageChannel, 0, len(packages)+1)
+	channels = append(channels, PackageChannel{Package: s.selfPackage, Channel: s.Get(s.selfPackage)})
+	for _, pkg := range packages {
+		channels = append(channels, PackageChannel{Package: pkg.Name, Channel: s.Get(pkg.Name)})
+	}
+
+	return channels
+}
+
+// Set switches the package to the channel, rewrites the repository sources and refreshes metadata.
+// The refresh may take longer than an API request is allowed to, the API runs switches as jobs.
+func (s *Service) Set(ctx context.Context, packageName string, channel package_manager.Channel) error {
+	if err := channel.Validate(); err != nil {
+		return err
+	}
+
+	// switches are requested by operators
+	ctx, release, err := s.coordinator.Acquire(ctx, coordinator.Operation{Name: "channel_switch", Package: packageName, Priority: coordinator.PriorityHigh})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err = s.packageManager.SetChannel(ctx, packageName, channel); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.overrides[packageName] = channel
+	err = jsonfile.Save(s.path, s.overrides)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("save channels: %w", err)
+	}
+
+	s.logger.Info("release channel switched", "pkg", packageName, "channel", channel)
+
+	return s.packageManager.UpdateRepository(ctx)
+}
@@ -0,0 +1,207 @@
+package coordinator
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Priority orders waiting operations, operations of the same priority run in arrival order.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+type Operation struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Package   string     `json:"package,omitempty"`
+	Priority  Priority   `json:"priority"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+}
+
+type ticket struct {
+	op    Operation
+	seq   uint64
+	ready chan struct{}
+}
+
+type heldKey struct{}
+
+// Service runs package manager mutations one at a time, so apt and yum never compete for their locks.
+type Service struct {
+	logger logger.Logger
+
+	mu      sync.Mutex
+	seq     uint64
+	running *ticket
+	waiting []*ticket
+}
+
+func NewService(l logger.Logger) *Service {
+	return &Service{logger: l}
+}
+
+// Acquire waits until the operation may run and returns a context marking the lock as held along with
+// its release func. Operations started with that context run right away, e.g. the rollback after a
+// failed health check. An ID is generated when op.ID is empty.
+func (s *Service) Acquire(ctx context.Context, op Operation) (context.Context, func(), error) {
+	if ctx.Value(heldKey{}) != nil {
+		return ctx, func() {}, nil
+	}
+
+	return s.Reserve(op).Wait(ctx)
+}
+
+// Reservation is a place in the queue taken by Reserve.
+type Reservation struct {
+	s *Service
+	t *ticket
+}
+
+// Reserve queues the operation right away, its turn is waited for with Wait. Callers which start
+// operations from goroutines reserve beforehand, so operations of the same priority keep their order.
+// An ID is generated when op.ID is empty.
+func (s *Service) Reserve(op Operation) *Reservation {
+	if op.ID == uuid.Nil {
+		op.ID = uuid.New()
+	}
+	op.QueuedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	t := &ticket{op: op, seq: s.seq, ready: make(chan struct{})}
+	i, _ := slices.BinarySearchFunc(s.waiting, t, compare)
+	s.waiting = slices.Insert(s.waiting, i, t)
+	s.dispatch()
+
+	return &Reservation{s: s, t: t}
+}
+
+// Wait waits until the reserved operation may run, see Acquire.
+func (r *Reservation) Wait(ctx context.Context) (context.Context, func(), error) {
+	s, t := r.s, r.t
+
+	select {
+	case <-t.ready:
+	case <-ctx.Done():
+		r.Cancel()
+		return nil, nil, fmt.Errorf("wait for %s: %w", t.op.Name, ctx.Err())
+	}
+
+	if waited := time.Since(t.op.QueuedAt); waited > time.Second {
+		s.logger.Info("operation waited for the lock", "operation", t.op.Name, "pkg", t.op.Package, "waited", waited.Round(time.Second))
+	}
+
+	var once sync.Once
+	return context.WithValue(ctx, heldKey{}, t.op.ID), func() { once.Do(func() { s.release(t) }) }, nil
+}
+
+// Cancel gives up the reservation which is not waited for.
+func (r *Reservation) Cancel() {
+	r.s.mu.Lock()
+	r.s.waiting = slices.DeleteFunc(r.s.waiting, func(w *ticket) bool { return w == r.t })
+	r.s.mu.Unlock()
+	// the lock may have been granted meanwhile
+	r.s.release(r.t)
+}
+
+// Position returns 0 for the running operation and the 1-based queue position of a waiting one.
+func (s *Service) Position(id uuid.UUID) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running != nil && s.running.op.ID == id {
+		return 0, true
+	}
+
+	for i, t := range s.waiting {
+		if t.op.ID == id {
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// List returns the running operation followed by the waiting ones in the order they will run.
+func (s *Service) List() []Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ops := make([]Operation, 0, len(s.waiting)+1)
+	if s.running != nil {
+		ops = append(ops, s.running.op)
+	}
+	for _, t := range s.waiting {
+		ops = append(ops, t.op)
+	}
+
+	return ops
+}
+
+func (s *Service) release(t *ticket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running != t {
+		return
+	}
+
+	s.running = nil
+	s.dispatch()
+}
+
+// dispatch starts the next operation when none is running. Must be called with mu held.
+func (s *Service) dispatch() {
+	if s.running != nil || len(s.waiting) == 0 {
+		return
+	}
+
+	t := s.waiting[0]
+	s.waiting = s.waiting[1:]
+
+	startedAt := time.Now()
+	t.op.StartedAt = &startedAt
+	s.running = t
+	close(t.ready)
+}
+
+// compare orders tickets by descending priority, then by arrival.
+func compare(a, b *ticket) int {
+	if a.op.Priority != b.op.Priority {
+		return cmp.Compare(b.op.Priority, a.op.Priority)
+	}
+
+	return cmp.Compare(a.seq, b.seq)
+}
@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/metrics"
+	"github.com/dv-net/dv-updater/pkg/logger"
+	"github.com/dv-net/dv-updater/pkg/retry"
+)
+
+const (
+	defaultTimeout = 60 * time.Second
+	probeInterval  = 3 * time.Second
+	probeTimeout   = 5 * time.Second
+)
+
+type Result struct {
+	Healthy    bool      `json:"healthy"`
+	Unit       string    `json:"unit,omitempty"`
+	UnitActive bool      `json:"unit_active"`
+	URL        string    `json:"url,omitempty"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+type Service struct {
+	logger logger.Logger
+	client *http.Client
+}
+
+func NewService(l logger.Logger) *Service {
+	return &Service{
+		logger: l,
+		client: &http.Client{Timeout: probeTimeout},
+	}
+}
+
+// Check waits until the systemd unit of the package is active and its probe URL answers 2xx.
+// Returns nil when the package has no health check enabled.
+func (s *Service) Check(ctx context.Context, pkg config.PackageConfig) *Result {
+	if !pkg.Health.Enabled {
+		return nil
+	}
+
+	timeout := pkg.Health.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	res := &Result{
+		Unit: pkg.Unit,
+		URL:  pkg.Health.URL,
+	}
+
+	err := retry.New(
+		retry.WithPolicy(retry.PolicyLinear),
+		retry.WithDelay(probeInterval),
+		retry.WithMaxAttempts(int(timeout/probeInterval)+1),
+		retry.WithOnRetry(metrics.RetryHook("health_check")),
+	).Do(func() error {
+		res.Attempts++
+		if err := s.probe(ctx, res); err != nil {
+			res.Error = err.Error()
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: %w", retry.ErrExit, ctx.Err())
+			}
+			return retry.ErrRetry
+		}
+
+		res.Error = ""
+		return nil
+	})
+
+	res.Healthy = err == nil
+	res.CheckedAt = time.Now()
+	if res.Healthy {
+		s.logger.Info("health check passed", "pkg", pkg.Name, "attempts", res.Attempts)
+	} else {
+		s.logger.Error("health check failed", errors.New(res.Error), "pkg", pkg.Name, "attempts", res.Attempts)
+	}
+
+	return res
+}
+
+func (s *Service) probe(ctx context.Context, res *Result) error {
+	if res.Unit != "" {
+		res.UnitActive = exec.CommandContext(ctx, "systemctl", "is-active", "--quiet", res.Unit).Run() == nil
+		if !res.UnitActive {
+			return fmt.Errorf("unit %s is not active", res.Unit)
+		}
+	}
+
+	if res.URL == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, res.URL, nil)
+	if err != nil {
+		return fmt.Errorf("build probe request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("probe %s: %w", res.URL, err)
+	}
+	_ = resp.Body.Close()
+
+	res.HTTPStatus = resp.StatusCode
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("probe %s answered %d", res.URL, resp.StatusCode)
+	}
+
+	return nil
+}
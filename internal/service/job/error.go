@@ -0,0 +1,8 @@
+package job
+
+import "errors"
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrQueueFull   = errors.New("job queue is full")
+)
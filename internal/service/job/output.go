@@ -0,0 +1,74 @@
+package job
+
+import (
+	"sync"
+)
+
+const maxOutputSize = 64 * 1024
+
+// outputBuffer keeps the tail of the command output, dropping the oldest bytes once the limit is reached.
+// Followers wait on the changed channel, it is closed and replaced on every write or job state change.
+type outputBuffer struct {
+	mu      sync.RWMutex
+	limit   int
+	buf     []byte
+	written int64
+	changed chan struct{}
+}
+
+func newOutputBuffer(limit int) *outputBuffer {
+	return &outputBuffer{
+		limit:   limit,
+		changed: make(chan struct{}),
+	}
+}
+
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf = append(o.buf, p...)
+	if overflow := len(o.buf) - o.limit; overflow > 0 {
+		o.buf = append(o.buf[:0], o.buf[overflow:]...)
+	}
+	o.written += int64(len(p))
+	o.broadcast()
+
+	return len(p), nil
+}
+
+func (o *outputBuffer) String() string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return string(o.buf)
+}
+
+// Since returns the kept output written after offset, the offset to continue from and a channel closed on the next change.
+func (o *outputBuffer) Since(offset int64) ([]byte, int64, <-chan struct{}) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	start := o.written - int64(len(o.buf))
+	offset = max(offset, start)
+	offset = min(offset, o.written)
+
+	out := make([]byte, o.written-offset)
+	copy(out, o.buf[offset-start:])
+
+	return out, o.written, o.changed
+}
+
+// Notify wakes up followers without writing anything.
+func (o *outputBuffer) Notify() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.broadcast()
+}
+
+// broadcast must be called with mu held.
+func (o *outputBuffer) broadcast() {
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
@@ -0,0 +1,294 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/service/coordinator"
+	"github.com/dv-net/dv-updater/internal/service/health"
+	"github.com/dv-net/dv-updater/internal/service/package_manager"
+	"github.com/dv-net/dv-updater/internal/service/updater"
+	"github.com/dv-net/dv-updater/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+type Operation string
+
+const (
+	OperationUpdate        Operation = "update"
+	OperationRollback      Operation = "rollback"
+	OperationChannelSwitch Operation = "channel_switch"
+)
+
+// Task is the work executed by a job. Everything written to output is captured into the job record.
+type Task func(ctx context.Context, output io.Writer) (updater.Result, error)
+
+// Job is an asynchronous package operation. QueuePosition of a queued job is 1 when it runs next.
+type Job struct {
+	ID            uuid.UUID                `json:"id"`
+	Operation     Operation                `json:"operation"`
+	Package       string                   `json:"package"`
+	State         State                    `json:"state"`
+	QueuePosition int                      `json:"queue_position,omitempty"`
+	CreatedAt     time.Time                `json:"created_at"`
+	StartedAt     *time.Time               `json:"started_at,omitempty"`
+	FinishedAt    *time.Time               `json:"finished_at,omitempty"`
+	Output        string                   `json:"output"`
+	Error         string                   `json:"error,omitempty"`
+	Result        *package_manager.Package `json:"result,omitempty"`
+	Health        *health.Result           `json:"health,omitempty"`
+	RolledBack    bool                     `json:"rolled_back,omitempty"`
+}
+
+// Finished reports whether the job is over.
+func (j Job) Finished() bool {
+	return j.State == StateSucceeded || j.State == StateFailed
+}
+
+// Update is the job state along with the output written after the followed offset.
+type Update struct {
+	Job     Job
+	Output  []byte
+	Offset  int64
+	Changed <-chan struct{}
+}
+
+type entry struct {
+	job         Job
+	task        Task
+	output      *outputBuffer
+	reservation *coordinator.Reservation
+}
+
+type Service struct {
+	logger       logger.Logger
+	coordinator  *coordinator.Service
+	historyLimit int
+	queueSize    int
+
+	mu    sync.RWMutex
+	jobs  map[uuid.UUID]*entry
+	order []uuid.UUID
+	queue chan *entry
+}
+
+func NewService(l logger.Logger, conf config.JobsConfig, coordinatorService *coordinator.Service) *Service {
+	return &Service{
+		logger:       l,
+		coordinator:  coordinatorService,
+		historyLimit: conf.HistoryLimit,
+		queueSize:    conf.QueueSize,
+		jobs:         make(map[uuid.UUID]*entry),
+		queue:        make(chan *entry, conf.QueueSize),
+	}
+}
+
+// Run starts queued jobs until ctx is done. Jobs wait for the turn they reserved in the operation
+// coordinator when enqueued.
+func (s *Service) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-s.queue:
+			go s.process(ctx, e)
+		}
+	}
+}
+
+func (s *Service) Enqueue(operation Operation, packageName string, priority coordinator.Priority, task Task) (Job, error) {
+	e := &entry{
+		job: Job{
+			ID:        uuid.New(),
+			Operation: operation,
+			Package:   packageName,
+			State:     StateQueued,
+			CreatedAt: time.Now(),
+		},
+		task:   task,
+		output: newOutputBuffer(maxOutputSize),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queued() >= s.queueSize {
+		return Job{}, ErrQueueFull
+	}
+
+	// the turn is taken here, jobs started concurrently by Run would race for it
+	e.reservation = s.coordinator.Reserve(coordinator.Operation{
+		ID:       e.job.ID,
+		Name:     string(operation),
+		Package:  packageName,
+		Priority: priority,
+	})
+
+	select {
+	case s.queue <- e:
+	default:
+		e.reservation.Cancel()
+		return Job{}, ErrQueueFull
+	}
+
+	s.jobs[e.job.ID] = e
+	s.order = append(s.order, e.job.ID)
+	s.evict()
+
+	s.logger.Info("job queued", "job", e.job.ID, "operation", operation, "pkg", packageName)
+
+	return s.snapshot(e), nil
+}
+
+func (s *Service) Get(id uuid.UUID) (Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	return s.snapshot(e), nil
+}
+
+// Follow returns the job state and its output written after offset. Update.Changed is closed once
+// there is more output or the state changes, follow again from Update.Offset then.
+func (s *Service) Follow(id uuid.UUID, offset int64) (Update, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.jobs[id]
+	if !ok {
+		return Update{}, ErrJobNotFound
+	}
+
+	output, next, changed := e.output.Since(offset)
+	return Update{
+		Job:     e.job,
+		Output:  output,
+		Offset:  next,
+		Changed: changed,
+	}, nil
+}
+
+func (s *Service) process(ctx context.Context, e *entry) {
+	ctx, release, err := e.reservation.Wait(ctx)
+	if err != nil {
+		s.fail(e, err)
+		return
+	}
+	defer release()
+
+	startedAt := time.Now()
+	s.mu.Lock()
+	e.job.State = StateRunning
+	e.job.StartedAt = &startedAt
+	e.output.Notify()
+	s.mu.Unlock()
+
+	s.logger.Info("job started", "job", e.job.ID, "pkg", e.job.Package)
+
+	res, err := s.run(ctx, e)
+
+	finishedAt := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer e.output.Notify()
+
+	e.job.FinishedAt = &finishedAt
+	e.job.Health = res.Health
+	e.job.RolledBack = res.RolledBack
+	if res.Package.Name != "" {
+		e.job.Result = &res.Package
+	}
+
+	if err != nil {
+		e.job.State = StateFailed
+		e.job.Error = err.Error()
+		s.logger.Error("job failed", err, "job", e.job.ID, "pkg", e.job.Package)
+		return
+	}
+
+	e.job.State = StateSucceeded
+	s.logger.Info("job succeeded", "job", e.job.ID, "pkg", e.job.Package)
+}
+
+func (s *Service) run(ctx context.Context, e *entry) (res updater.Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return e.task(ctx, e.output)
+}
+
+// fail finishes a job which could not start.
+func (s *Service) fail(e *entry, err error) {
+	finishedAt := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer e.output.Notify()
+
+	e.job.FinishedAt = &finishedAt
+	e.job.State = StateFailed
+	e.job.Error = err.Error()
+	s.logger.Error("job failed", err, "job", e.job.ID, "pkg", e.job.Package)
+}
+
+// queued counts the jobs waiting to run. Must be called with mu held.
+func (s *Service) queued() int {
+	n := 0
+	for _, e := range s.jobs {
+		if e.job.State == StateQueued {
+			n++
+		}
+	}
+
+	return n
+}
+
+// evict drops the oldest finished jobs once the history limit is exceeded. Must be called with mu held.
+func (s *Service) evict() {
+	overflow := len(s.order) - s.historyLimit
+	if overflow <= 0 {
+		return
+	}
+
+	kept := s.order[:0]
+	for _, id := range s.order {
+		e := s.jobs[id]
+		if overflow > 0 && e.job.Finished() {
+			delete(s.jobs, id)
+			overflow--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+// snapshot returns a copy of the job safe to hand out. Must be called with mu held.
+func (s *Service) snapshot(e *entry) Job {
+	job := e.job
+	job.Output = e.output.String()
+	if job.State == StateQueued {
+		job.QueuePosition, _ = s.coordinator.Position(job.ID)
+	}
+	return job
+}
@@ -0,0 +1,420 @@
+package package_manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/pkg/logger"
+)
+
+// ApkManager manages packages on Alpine. The dvnet repositories of the channels are passed to every
+// command with --repository, /etc/apk/repositories of the image is left untouched. apk runs without
+// sudo when the updater is root, as usual in containers.
+type ApkManager struct {
+	logger   logger.Logger
+	repoConf config.RepositoryConfig
+
+	mu       sync.RWMutex
+	channels map[string]Channel
+}
+
+const apkKeysDir = "/etc/apk/keys"
+
+var _ PackageManager = (*ApkManager)(nil)
+
+func NewApkManager(l logger.Logger, repoConf config.RepositoryConfig) *ApkManager {
+	return &ApkManager{
+		logger:   l,
+		repoConf: repoConf,
+		channels: make(map[string]Channel),
+	}
+}
+
+func (a *ApkManager) GetInstalledPackage(ctx context.Context, packageName string) (Package, error) {
+	installed, _, err := a.version(ctx, packageName)
+	if err != nil {
+		return Package{}, err
+	}
+
+	return Package{
+		Name:             packageName,
+		InstalledVersion: installed,
+		AvailableVersion: installed,
+	}, nil
+}
+
+func (a *ApkManager) CheckForUpdates(ctx context.Context, packageName string) (Package, error) {
+	installed, available, err := a.version(ctx, packageName)
+	if err != nil {
+		return Package{}, err
+	}
+
+	// the package is not offered by any repository
+	if available == "" {
+		available = installed
+	}
+
+	return Package{
+		Name:             packageName,
+		InstalledVersion: installed,
+		AvailableVersion: available,
+		NeedForUpdate:    needForUpdate(installed, available, CompareAPKVersions),
+	}, nil
+}
+
+func (a *ApkManager) UpgradePackage(ctx context.Context, packageName string, output io.Writer) error {
+	a.logger.Info("Attempting to upgrade package", "pkg", packageName)
+	if err := a.run(ctx, output, a.args(packageName, "add", "--upgrade", packageName)...); err != nil {
+		a.logger.Error("Failed to upgrade package", err, "pkg", packageName)
+		return fmt.Errorf("failed to upgrade package %s: %w", packageName, err)
+	}
+
+	a.logger.Info("Package upgraded successfully", "pkg", packageName)
+	return nil
+}
+
+// InstallPackage pins the package to the version in the world file, the next UpgradePackage lifts the pin.
+func (a *ApkManager) InstallPackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	versions, err := a.availableVersions(ctx, packageName)
+	if err != nil {
+		return err
+	}
+
+	fullVersion, ok := matchVersion(versions, version)
+	if !ok {
+		return fmt.Errorf("%w: %s-%s", ErrVersionNotFound, packageName, version)
+	}
+
+	a.logger.Info("Attempting to install package version", "pkg", packageName, "version", fullVersion)
+	if err = a.run(ctx, output, a.args(packageName, "add", packageName+"="+fullVersion)...); err != nil {
+		a.logger.Error("Failed to install package version", err, "pkg", packageName, "version", fullVersion)
+		return fmt.Errorf("failed to install package %s=%s: %w", packageName, fullVersion, err)
+	}
+
+	a.logger.Info("Package version installed successfully", "pkg", packageName, "version", fullVersion)
+	return nil
+}
+
+func (a *ApkManager) DowngradePackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	a.logger.Info("Attempting to downgrade package", "pkg", packageName, "version", version)
+	if err := a.run(ctx, output, a.args(packageName, "add", packageName+"="+version)...); err != nil {
+		a.logger.Error("Failed to downgrade package", err, "pkg", packageName, "version", version)
+		return fmt.Errorf("failed to downgrade package %s=%s: %w", packageName, version, err)
+	}
+
+	a.logger.Info("Package downgraded successfully", "pkg", packageName, "version", version)
+	return nil
+}
+
+func (a *ApkManager) ListVersions(ctx context.Context, packageName string) ([]PackageVersion, error) {
+	available, err := a.availableVersions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed string
+	if pkg, err := a.GetInstalledPackage(ctx, packageName); err == nil {
+		installed = pkg.InstalledVersion
+	}
+
+	return buildVersionList(available, installed, CompareAPKVersions), nil
+}
+
+func (a *ApkManager) UpdateRepository(ctx context.Context) error {
+	if err := a.installKey(ctx); err != nil {
+		return err
+	}
+
+	a.mu.RLock()
+	channels := channelsInUse(a.channels)
+	a.mu.RUnlock()
+
+	var args []string
+	if a.repoConf.ApkURL != "" {
+		for _, c := range channels {
+			args = append(args, "--repository", a.repositoryURL(c))
+		}
+	}
+	args = append(args, "update")
+
+	out, err := a.command(ctx, args...).CombinedOutput()
+	if err != nil {
+		a.logger.Error("Failed to update package index", err, "out", string(out))
+		return fmt.Errorf("failed to update package index: %w", err)
+	}
+
+	a.logger.Info("Package list updated successfully")
+	a.logger.Debug("Output: %s", string(out))
+	return nil
+}
+
+func (a *ApkManager) SetChannel(_ context.Context, packageName string, channel Channel) error {
+	if err := channel.Validate(); err != nil {
+		return err
+	}
+
+	if a.repoConf.ApkURL == "" {
+		if channel != ChannelStable {
+			return ErrChannelsNotConfigured
+		}
+		return nil
+	}
+
+	a.mu.Lock()
+	a.channels[packageName] = channel
+	a.mu.Unlock()
+
+	a.logger.Info("Package release channel changed", "pkg", packageName, "channel", channel)
+	return nil
+}
+
+func (a *ApkManager) SimulateUpgrade(ctx context.Context, packageName, version string) (UpgradePlan, error) {
+	target := packageName
+	if version != "" {
+		versions, err := a.availableVersions(ctx, packageName)
+		if err != nil {
+			return UpgradePlan{}, err
+		}
+
+		fullVersion, ok := matchVersion(versions, version)
+		if !ok {
+			return UpgradePlan{}, fmt.Errorf("%w: %s-%s", ErrVersionNotFound, packageName, version)
+		}
+		target = packageName + "=" + fullVersion
+	}
+
+	out, err := a.command(ctx, a.args(packageName, "add", "--simulate", "--upgrade", target)...).CombinedOutput()
+	if err != nil {
+		a.logger.Error("Failed to simulate upgrade", err, "pkg", packageName, "out", string(out))
+		return UpgradePlan{}, fmt.Errorf("failed to simulate upgrade of %s: %w, output: %s", packageName, err, string(out))
+	}
+
+	return UpgradePlan{
+		Package: packageName,
+		Version: version,
+		Changes: parseApkSimulation(out),
+	}, nil
+}
+
+// version returns the installed version of the package and the newest one in the repositories.
+func (a *ApkManager) version(ctx context.Context, packageName string) (string, string, error) {
+	out, err := a.command(ctx, a.args(packageName, "version", packageName)...).Output()
+	if err != nil {
+		a.logger.Error("Failed to get package version", err, "pkg", packageName)
+		return "", "", fmt.Errorf("failed to get version of %s: %w", packageName, err)
+	}
+
+	installed, available, ok := parseApkVersionOutput(out, packageName)
+	if !ok {
+		return "", "", fmt.Errorf("package %s is not installed", packageName)
+	}
+
+	return installed, available, nil
+}
+
+// availableVersions returns every version of the package in the repositories.
+func (a *ApkManager) availableVersions(ctx context.Context, packageName string) ([]string, error) {
+	out, err := a.command(ctx, a.args(packageName, "search", "--exact", "--all", packageName)...).Output()
+	if err != nil {
+		a.logger.Error("Failed to list package versions", err, "pkg", packageName)
+		return nil, fmt.Errorf("failed to list versions of %s: %w", packageName, err)
+	}
+
+	var versions []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if v, ok := apkPackageVersion(strings.TrimSpace(line), packageName); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	return versions, nil
+}
+
+// args adds the dvnet repositories of the package channel to the apk applet arguments.
+func (a *ApkManager) args(packageName, applet string, args ...string) []string {
+	if a.repoConf.ApkURL == "" {
+		return append([]string{applet}, args...)
+	}
+
+	a.mu.RLock()
+	channel, ok := a.channels[packageName]
+	a.mu.RUnlock()
+
+	repos := []string{"--repository", a.repositoryURL(ChannelStable)}
+	if ok && channel != ChannelStable {
+		repos = append(repos, "--repository", a.repositoryURL(channel))
+	}
+
+	return append(append(repos, applet), args...)
+}
+
+func (a *ApkManager) repositoryURL(channel Channel) string {
+	return strings.ReplaceAll(a.repoConf.ApkURL, channelToken, string(channel))
+}
+
+func (a *ApkManager) command(ctx context.Context, args ...string) *exec.Cmd {
+	return a.privileged(ctx, "apk", args...)
+}
+
+// privileged runs the command through sudo unless the updater is root.
+func (a *ApkManager) privileged(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if os.Geteuid() == 0 {
+		return exec.CommandContext(ctx, name, args...)
+	}
+
+	return exec.CommandContext(ctx, "sudo", append([]string{name}, args...)...) //nolint:gosec
+}
+
+// installKey copies the public key the dvnet repository is signed with to /etc/apk/keys. apk looks
+// keys up by the file name recorded in the index signature, so the name of the source is kept.
+// Only root writes the key, sudo rules cannot limit tee to a single unknown file name. Unprivileged
+// updaters rely on the package scripts installing the key from DV_APK_KEY.
+func (a *ApkManager) installKey(ctx context.Context) error {
+	if a.repoConf.ApkKey == "" {
+		return nil
+	}
+
+	u, err := url.Parse(a.repoConf.ApkKey)
+	if err != nil {
+		return fmt.Errorf("invalid apk key location: %w", err)
+	}
+
+	keyPath := path.Join(apkKeysDir, path.Base(u.Path))
+	if _, err = os.Stat(keyPath); err == nil {
+		return nil
+	}
+
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("apk key %s is not installed, install it as root or with DV_APK_KEY set for the package scripts", keyPath)
+	}
+
+	var key []byte
+	if u.Scheme == "http" || u.Scheme == "https" {
+		key, err = fetchKey(ctx, u.String())
+	} else {
+		key, err = os.ReadFile(a.repoConf.ApkKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read apk key: %w", err)
+	}
+
+	if err = os.WriteFile(keyPath, key, 0o644); err != nil { //nolint:gosec
+		a.logger.Error("Failed to install apk key", err, "path", keyPath)
+		return fmt.Errorf("failed to write %s: %w", keyPath, err)
+	}
+
+	a.logger.Info("Repository signing key installed", "path", keyPath)
+	return nil
+}
+
+func fetchKey(ctx context.Context, keyURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+}
+
+func (a *ApkManager) run(ctx context.Context, output io.Writer, args ...string) error {
+	cmd := a.command(ctx, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	return cmd.Run()
+}
+
+// parseApkVersionOutput parses the output of "apk version":
+//
+//	Installed:                                Available:
+//	dv-merchant-1.4.1-r0                    < 1.4.2-r0
+//
+// The available version is empty when no repository offers the package.
+func parseApkVersionOutput(out []byte, packageName string) (string, string, bool) {
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		installed, ok := apkPackageVersion(fields[0], packageName)
+		if !ok {
+			continue
+		}
+
+		var available string
+		if len(fields) >= 3 {
+			available = fields[2]
+		}
+
+		return installed, available, true
+	}
+
+	return "", "", false
+}
+
+// apkPackageVersion extracts the version from "<name>-<version>-r<N>".
+func apkPackageVersion(nameVersion, packageName string) (string, bool) {
+	version, ok := strings.CutPrefix(nameVersion, packageName+"-")
+	if !ok || version == "" || !isDigit(version[0]) {
+		return "", false
+	}
+
+	return version, true
+}
+
+// apkChangeRe matches lines like "(1/2) Upgrading dv-merchant (1.4.1-r0 -> 1.4.2-r0)".
+var apkChangeRe = regexp.MustCompile(`^\(\d+/\d+\) (\w+) (\S+) \((\S+)(?: -> (\S+))?\)`)
+
+// parseApkSimulation parses the output of "apk add --simulate".
+func parseApkSimulation(out []byte) []PlannedChange {
+	changes := make([]PlannedChange, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		m := apkChangeRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		change := PlannedChange{Name: m[2], CurrentVersion: m[3], Version: m[4]}
+		switch m[1] {
+		case "Installing":
+			change.Action, change.CurrentVersion, change.Version = ActionInstall, "", m[3]
+		case "Upgrading":
+			change.Action = ActionUpgrade
+		case "Downgrading":
+			change.Action = ActionDowngrade
+		case "Reinstalling", "Replacing":
+			change.Action = ActionReinstall
+			if change.Version == "" {
+				change.Version = change.CurrentVersion
+			}
+		case "Purging", "Deleting":
+			change.Action = ActionRemove
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
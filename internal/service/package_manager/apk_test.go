@@ -0,0 +1,117 @@
+package package_manager
+
+import "testing"
+
+func TestParseApkVersionOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		out           string
+		pkg           string
+		wantInstalled string
+		wantAvailable string
+		wantOK        bool
+	}{
+		{
+			name: "update available",
+			out: `Installed:                                Available:
+dv-merchant-1.4.1-r0                    < 1.4.2-r0
+`,
+			pkg:           "dv-merchant",
+			wantInstalled: "1.4.1-r0",
+			wantAvailable: "1.4.2-r0",
+			wantOK:        true,
+		},
+		{
+			name: "up to date",
+			out: `Installed:                                Available:
+dv-merchant-1.4.2-r0                    = 1.4.2-r0
+`,
+			pkg:           "dv-merchant",
+			wantInstalled: "1.4.2-r0",
+			wantAvailable: "1.4.2-r0",
+			wantOK:        true,
+		},
+		{
+			name: "not offered by any repository",
+			out: `Installed:                                Available:
+dv-merchant-1.4.2-r0                    ?
+`,
+			pkg:           "dv-merchant",
+			wantInstalled: "1.4.2-r0",
+			wantOK:        true,
+		},
+		{
+			name: "package with a longer name sharing the prefix",
+			out: `Installed:                                Available:
+dv-merchant-migrations-1.4.1-r0         < 1.4.2-r0
+dv-merchant-1.4.2_rc1-r1                < 1.4.2-r0
+`,
+			pkg:           "dv-merchant",
+			wantInstalled: "1.4.2_rc1-r1",
+			wantAvailable: "1.4.2-r0",
+			wantOK:        true,
+		},
+		{
+			name:   "not installed",
+			out:    "Installed:                                Available:\n",
+			pkg:    "dv-merchant",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installed, available, ok := parseApkVersionOutput([]byte(tt.out), tt.pkg)
+			if installed != tt.wantInstalled || available != tt.wantAvailable || ok != tt.wantOK {
+				t.Errorf("parseApkVersionOutput() = %q, %q, %v, want %q, %q, %v",
+					installed, available, ok, tt.wantInstalled, tt.wantAvailable, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseApkSimulation(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []PlannedChange
+	}{
+		{
+			name: "upgrade with dependencies",
+			out: `fetch https://repo.example.com/alpine/stable/main/x86_64/APKINDEX.tar.gz
+fetch https://dl-cdn.alpinelinux.org/alpine/v3.20/main/x86_64/APKINDEX.tar.gz
+(1/3) Purging dv-legacy (0.1.0-r0)
+(2/3) Installing dv-merchant-migrations (1.4.2-r0)
+(3/3) Upgrading dv-merchant (1.4.1-r0 -> 1.4.2-r0)
+OK: 42 MiB in 30 packages
+`,
+			want: []PlannedChange{
+				{Name: "dv-legacy", Action: ActionRemove, CurrentVersion: "0.1.0-r0"},
+				{Name: "dv-merchant-migrations", Action: ActionInstall, Version: "1.4.2-r0"},
+				{Name: "dv-merchant", Action: ActionUpgrade, CurrentVersion: "1.4.1-r0", Version: "1.4.2-r0"},
+			},
+		},
+		{
+			name: "downgrade and reinstall",
+			out: `(1/2) Downgrading dv-merchant (1.4.2-r0 -> 1.4.2_rc1-r0)
+(2/2) Replacing dv-processing (0.9.3-r0)
+OK: 42 MiB in 30 packages
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionDowngrade, CurrentVersion: "1.4.2-r0", Version: "1.4.2_rc1-r0"},
+				{Name: "dv-processing", Action: ActionReinstall, CurrentVersion: "0.9.3-r0", Version: "0.9.3-r0"},
+			},
+		},
+		{
+			name: "nothing to do",
+			out:  "OK: 42 MiB in 30 packages\n",
+			want: []PlannedChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertChanges(t, parseApkSimulation([]byte(tt.out)), tt.want)
+		})
+	}
+}
@@ -1,17 +1,22 @@
 package package_manager
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/metrics"
 	"github.com/dv-net/dv-updater/pkg/logger"
 	"github.com/dv-net/dv-updater/pkg/retry"
 )
@@ -19,14 +24,19 @@ import (
 type AptManager struct {
 	logger     logger.Logger
 	binaryPath string
+	repoConf   config.RepositoryConfig
+
+	mu       sync.RWMutex
+	channels map[string]Channel
 }
 
 const repo = "sources.list.d/dvnet.list"
+const aptSourcesPath = "/etc/apt/" + repo
 const flagForceUpdate = "--force-confold"
 
 var _ PackageManager = (*AptManager)(nil)
 
-func NewAptManager(l logger.Logger) (*AptManager, error) {
+func NewAptManager(l logger.Logger, repoConf config.RepositoryConfig) (*AptManager, error) {
 	binaryPath, err := os.Executable()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get binary path: %w", err)
@@ -35,6 +45,8 @@ func NewAptManager(l logger.Logger) (*AptManager, error) {
 	return &AptManager{
 		logger:     l,
 		binaryPath: binaryPath,
+		repoConf:   repoConf,
+		channels:   make(map[string]Channel),
 	}, nil
 }
 
@@ -49,7 +61,8 @@ func (a *AptManager) GetInstalledPackage(ctx context.Context, packageName string
 }
 
 func (a *AptManager) CheckForUpdates(ctx context.Context, packageName string) (Package, error) {
-	out, err := exec.CommandContext(ctx, "sudo", "apt", "list", "--upgradable", packageName).Output()
+	args := append([]string{"apt"}, a.releaseOptions(packageName)...)
+	out, err := exec.CommandContext(ctx, "sudo", append(args, "list", "--upgradable", packageName)...).Output()
 	if err != nil {
 		a.logger.Error("Failed to check for updates: %s", err)
 		return Package{}, ErrNothingToUpdate
@@ -58,15 +71,16 @@ func (a *AptManager) CheckForUpdates(ctx context.Context, packageName string) (P
 	return a.parseAptOutput(out, packageName)
 }
 
-func (a *AptManager) UpgradePackage(ctx context.Context, packageName string) error {
+func (a *AptManager) UpgradePackage(ctx context.Context, packageName string, output io.Writer) error {
 	a.logger.Error("Attempting to upgrade package", nil, "pkg", packageName)
-	err := a.runAptCommandWithSpinLock(ctx, "install", "-o", "Dpkg::Options::="+flagForceUpdate, "-y", "--only-upgrade", packageName)
+	args := append(a.releaseOptions(packageName), "install", "-o", "Dpkg::Options::="+flagForceUpdate, "-y", "--only-upgrade", packageName)
+	err := a.runAptCommandWithSpinLock(ctx, output, args...)
 	if err != nil {
 		a.logger.Error("Failed to upgrade package", err, "pkg", packageName)
 		pkg, checkErr := a.CheckForUpdates(ctx, packageName)
 		if checkErr != nil || pkg.NeedForUpdate {
 			a.logger.Error("Package still needs update after dpkg configure", nil, "pkg", packageName, "checkErr", checkErr)
-			return fmt.Errorf("failed to update package %s: still needs update", packageName)
+			return fmt.Errorf("failed to update package %s: still needs update: %w", packageName, err)
 		}
 		a.logger.Info("Package updated successfully", "pkg", packageName)
 	} else {
@@ -84,12 +98,49 @@ func (a *AptManager) UpgradePackage(ctx context.Context, packageName string) err
 	return nil
 }
 
+func (a *AptManager) InstallPackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	if err := a.ensureVersionAvailable(ctx, packageName, version); err != nil {
+		return err
+	}
+
+	a.logger.Info("Attempting to install package version", "pkg", packageName, "version", version)
+	// the version may be older than the installed one
+	args := append(a.releaseOptions(packageName), "install", "-o", "Dpkg::Options::="+flagForceUpdate, "-y", "--allow-downgrades", packageName+"="+version)
+	err := a.runAptCommandWithSpinLock(ctx, output, args...)
+	if err != nil {
+		a.logger.Error("Failed to install package version", err, "pkg", packageName, "version", version)
+		return fmt.Errorf("failed to install package %s=%s: %w", packageName, version, err)
+	}
+
+	a.logger.Info("Package version installed successfully", "pkg", packageName, "version", version)
+	return nil
+}
+
+// DowngradePackage installs the older version, apt installs pinned versions in both directions.
+func (a *AptManager) DowngradePackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	return a.InstallPackage(ctx, packageName, version, output)
+}
+
+func (a *AptManager) ListVersions(ctx context.Context, packageName string) ([]PackageVersion, error) {
+	available, err := a.availableVersions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed string
+	if pkg, err := a.GetInstalledPackage(ctx, packageName); err == nil {
+		installed = pkg.InstalledVersion
+	}
+
+	return buildVersionList(available, installed, CompareDebianVersions), nil
+}
+
 func (a *AptManager) UpdateRepository(ctx context.Context) error {
 	a.logger.Info("start Updating repository")
 	out, err := exec.CommandContext(ctx, "sudo", "apt", "update", "-o", "Dir::Etc::sourcelist="+repo).CombinedOutput()
 	if err != nil {
 		a.logger.Error("Failed to update package: %v", err, "out", string(out))
-		return errors.New("failed to update package list")
+		return fmt.Errorf("failed to update package list: %w", err)
 	}
 	a.logger.Info("Package list updated successfully")
 	a.logger.Debug("Output: %s", string(out))
@@ -97,12 +148,93 @@ func (a *AptManager) UpdateRepository(ctx context.Context) error {
 	return nil
 }
 
+func (a *AptManager) SimulateUpgrade(ctx context.Context, packageName, version string) (UpgradePlan, error) {
+	args := append(a.releaseOptions(packageName), "-s", "install")
+	if version != "" {
+		if err := a.ensureVersionAvailable(ctx, packageName, version); err != nil {
+			return UpgradePlan{}, err
+		}
+		args = append(args, "--allow-downgrades", packageName+"="+version)
+	} else {
+		args = append(args, "--only-upgrade", packageName)
+	}
+
+	// simulation does not need root privileges nor the dpkg lock
+	out, err := exec.CommandContext(ctx, "apt-get", args...).CombinedOutput()
+	if err != nil {
+		a.logger.Error("Failed to simulate upgrade", err, "pkg", packageName, "out", string(out))
+		return UpgradePlan{}, fmt.Errorf("failed to simulate upgrade of %s: %w, output: %s", packageName, err, string(out))
+	}
+
+	return UpgradePlan{
+		Package: packageName,
+		Version: version,
+		Changes: parseAptSimulation(out),
+	}, nil
+}
+
+// SetChannel makes the package follow the release channel. The dvnet source entries are rewritten
+// to contain a suite for every channel in use, candidates are taken from the package channel suite.
+func (a *AptManager) SetChannel(ctx context.Context, packageName string, channel Channel) error {
+	if err := channel.Validate(); err != nil {
+		return err
+	}
+
+	if a.repoConf.AptURL == "" {
+		if channel != ChannelStable {
+			return ErrChannelsNotConfigured
+		}
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.channels[packageName] = channel
+
+	var sources strings.Builder
+	for _, c := range channelsInUse(a.channels) {
+		entry := strings.Join(strings.Fields(fmt.Sprintf("deb %s %s %s main", a.repoConf.AptOptions, a.repoConf.AptURL, c)), " ")
+		sources.WriteString(entry + "\n")
+	}
+
+	cmd := exec.CommandContext(ctx, "sudo", "tee", aptSourcesPath)
+	cmd.Stdin = strings.NewReader(sources.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		a.logger.Error("Failed to write apt sources", err, "out", string(out))
+		return fmt.Errorf("failed to write %s: %w", aptSourcesPath, err)
+	}
+
+	a.logger.Info("Package release channel changed", "pkg", packageName, "channel", channel)
+	return nil
+}
+
+// releaseOptions prefers the suite of the package channel when channels are configured.
+func (a *AptManager) releaseOptions(packageName string) []string {
+	if a.repoConf.AptURL == "" {
+		return nil
+	}
+
+	return []string{"-o", "APT::Default-Release=" + string(a.channelOf(packageName))}
+}
+
+func (a *AptManager) channelOf(packageName string) Channel {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if c, ok := a.channels[packageName]; ok {
+		return c
+	}
+
+	return ChannelStable
+}
+
 func (a *AptManager) parseAptOutput(out []byte, packageName string) (Package, error) {
 	lines := strings.Split(string(out), "\n")
 
 	var installedVersion, availableVersion string
 
-	regexPattern := fmt.Sprintf(`^%s/(?:unknown(?:,now)?)?\s*(\S+)\s+amd64\s+\[(?:installed|upgradable from:\s*(\S+))\]`, regexp.QuoteMeta(packageName))
+	regexPattern := fmt.Sprintf(`^%s/(?:unknown(?:,now)?)?\s*(\S+)\s+amd64\s+\[(?:installed(?:,[^\]]*)?|upgradable from:\s*(\S+))\]`, regexp.QuoteMeta(packageName))
 	re := regexp.MustCompile(regexPattern)
 
 	for _, line := range lines {
@@ -127,26 +259,89 @@ func (a *AptManager) parseAptOutput(out []byte, packageName string) (Package, er
 		Name:             packageName,
 		InstalledVersion: installedVersion,
 		AvailableVersion: availableVersion,
-		NeedForUpdate:    installedVersion != availableVersion,
+		NeedForUpdate:    needForUpdate(installedVersion, availableVersion, CompareDebianVersions),
 	}, nil
 }
 
-func (a *AptManager) runAptCommandWithSpinLock(ctx context.Context, args ...string) error {
+func (a *AptManager) ensureVersionAvailable(ctx context.Context, packageName, version string) error {
+	versions, err := a.availableVersions(ctx, packageName)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		if v == version {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s=%s", ErrVersionNotFound, packageName, version)
+}
+
+// availableVersions returns every version of the package offered by the dvnet repository.
+func (a *AptManager) availableVersions(ctx context.Context, packageName string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, "apt-cache", "-o", "Dir::Etc::sourcelist="+repo, "madison", packageName).Output()
+	if err != nil {
+		a.logger.Error("Failed to list package versions", err, "pkg", packageName)
+		return nil, fmt.Errorf("failed to list versions of %s: %w", packageName, err)
+	}
+
+	suite := ""
+	if a.repoConf.AptURL != "" {
+		suite = string(a.channelOf(packageName))
+	}
+
+	return a.parseMadisonOutput(out, packageName, suite), nil
+}
+
+// parseMadisonOutput parses lines like " dv-merchant |  1.4.2 | https://repo stable/main amd64 Packages".
+// When suite is not empty only versions from that suite are returned.
+func (a *AptManager) parseMadisonOutput(out []byte, packageName, suite string) []string {
+	var versions []string
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Split(line, "|")
+		if len(parts) < 3 || strings.TrimSpace(parts[0]) != packageName {
+			continue
+		}
+
+		// Skip the dpkg status entry, it is not offered by any repository
+		if strings.Contains(parts[2], "/var/lib/dpkg/status") {
+			continue
+		}
+
+		if source := strings.Fields(parts[2]); suite != "" && (len(source) < 2 || strings.Split(source[1], "/")[0] != suite) {
+			continue
+		}
+
+		versions = append(versions, strings.TrimSpace(parts[1]))
+	}
+
+	return versions
+}
+
+func (a *AptManager) runAptCommandWithSpinLock(ctx context.Context, output io.Writer, args ...string) error {
 	return retry.New(
 		retry.WithPolicy(retry.PolicyLinear),
 		retry.WithDelay(5*time.Second),
 		retry.WithMaxAttempts(5),
+		retry.WithOnRetry(metrics.RetryHook("apt")),
 	).Do(func() error {
 		if a.isDpkgLocked(ctx) {
+			metrics.DpkgLockWait()
 			a.logger.Error("dpkg lock detected, retrying in 5s", nil)
 			return retry.ErrRetry
 		}
 
 		cmdArgs := append([]string{"sudo", "apt"}, args...)
 		cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...) //nolint:gosec
-		out, err := cmd.CombinedOutput()
+		buf := new(bytes.Buffer)
+		cmd.Stdout = io.MultiWriter(buf, output)
+		cmd.Stderr = cmd.Stdout
+		err := cmd.Run()
+		out := buf.Bytes()
 		if err != nil {
 			if a.isLockError(err) {
+				metrics.DpkgLockWait()
 				out, errDpkg := exec.CommandContext(ctx, "sudo", "dpkg", "--configure", "-a").CombinedOutput()
 				if errDpkg != nil {
 					a.logger.Error("Failed to configure dpkg", errDpkg, "pkg", "packageName", "out", string(out))
@@ -193,6 +388,7 @@ func (a *AptManager) selfUpdate(ctx context.Context) error {
 		retry.WithPolicy(retry.PolicyLinear),
 		retry.WithDelay(2*time.Second),
 		retry.WithMaxAttempts(5),
+		retry.WithOnRetry(metrics.RetryHook("self_update")),
 	).Do(func() error {
 		cmd := exec.CommandContext(ctx, "fuser", cleanPath)
 		if output, err := cmd.CombinedOutput(); err == nil && len(output) > 0 {
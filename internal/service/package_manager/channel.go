@@ -0,0 +1,35 @@
+package package_manager
+
+import "fmt"
+
+type Channel string
+
+const (
+	ChannelStable  Channel = "stable"
+	ChannelRC      Channel = "rc"
+	ChannelNightly Channel = "nightly"
+)
+
+func (c Channel) Validate() error {
+	switch c {
+	case ChannelStable, ChannelRC, ChannelNightly:
+		return nil
+	default:
+		return fmt.Errorf("invalid release channel %q", c)
+	}
+}
+
+// channelsInUse returns stable followed by every other channel some package follows.
+func channelsInUse(channels map[string]Channel) []Channel {
+	used := []Channel{ChannelStable}
+	for _, c := range []Channel{ChannelRC, ChannelNightly} {
+		for _, pc := range channels {
+			if pc == c {
+				used = append(used, c)
+				break
+			}
+		}
+	}
+
+	return used
+}
@@ -0,0 +1,279 @@
+package package_manager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/pkg/logger"
+)
+
+const (
+	dnfBinary  = "dnf"
+	dnf5Binary = "dnf5"
+)
+
+// DnfManager manages packages with dnf or dnf5. Versions are queried with rpm and repoquery query
+// formats instead of parsing list tables, which differ between dnf generations.
+type DnfManager struct {
+	logger logger.Logger
+	binary string
+	repos  *rpmRepos
+}
+
+var _ PackageManager = (*DnfManager)(nil)
+
+// DnfAvailable reports whether dnf or dnf5 is installed.
+func DnfAvailable() bool {
+	return dnfBinaryPath() != ""
+}
+
+// NewDnfManager uses dnf5 when it is installed and dnf otherwise.
+func NewDnfManager(log logger.Logger, repoConf config.RepositoryConfig) *DnfManager {
+	binary := dnfBinaryPath()
+	if binary == "" {
+		binary = dnfBinary
+	}
+
+	return &DnfManager{
+		logger: log,
+		binary: binary,
+		repos:  newRPMRepos(log, repoConf, yumReposPath),
+	}
+}
+
+func dnfBinaryPath() string {
+	for _, binary := range []string{dnf5Binary, dnfBinary} {
+		if _, err := exec.LookPath(binary); err == nil {
+			return binary
+		}
+	}
+
+	return ""
+}
+
+func (d *DnfManager) GetInstalledPackage(ctx context.Context, packageName string) (Package, error) {
+	return installedRPMPackage(ctx, d.logger, packageName)
+}
+
+func (d *DnfManager) CheckForUpdates(ctx context.Context, packageName string) (Package, error) {
+	pkg, err := d.GetInstalledPackage(ctx, packageName)
+	if err != nil {
+		return Package{}, err
+	}
+
+	available, err := d.availableVersions(ctx, packageName)
+	if err != nil {
+		return Package{}, ErrNothingToUpdate
+	}
+
+	if latest := latestRPMVersion(available); latest != "" {
+		pkg.AvailableVersion = latest
+	}
+	pkg.NeedForUpdate = needForUpdate(pkg.InstalledVersion, pkg.AvailableVersion, CompareRPMVersions)
+
+	return pkg, nil
+}
+
+func (d *DnfManager) UpgradePackage(ctx context.Context, packageName string, output io.Writer) error {
+	d.logger.Info("Attempting to upgrade package", "pkg", packageName)
+	if err := d.run(ctx, output, "--repo", d.repos.repoOf(packageName), "upgrade", "-y", packageName); err != nil {
+		d.logger.Error("Failed to upgrade package", err, "pkg", packageName)
+		return fmt.Errorf("failed to upgrade package %s: %w", packageName, err)
+	}
+
+	d.logger.Info("Package upgraded successfully", "pkg", packageName)
+	return nil
+}
+
+func (d *DnfManager) InstallPackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	fullVersion, err := d.resolveVersion(ctx, packageName, version)
+	if err != nil {
+		return err
+	}
+
+	d.logger.Info("Attempting to install package version", "pkg", packageName, "version", fullVersion)
+	if err = d.run(ctx, output, "--repo", d.repos.repoOf(packageName), "install", "-y", packageName+"-"+fullVersion); err != nil {
+		d.logger.Error("Failed to install package version", err, "pkg", packageName, "version", fullVersion)
+		return fmt.Errorf("failed to install package %s-%s: %w", packageName, fullVersion, err)
+	}
+
+	d.logger.Info("Package version installed successfully", "pkg", packageName, "version", fullVersion)
+	return nil
+}
+
+func (d *DnfManager) DowngradePackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	d.logger.Info("Attempting to downgrade package", "pkg", packageName, "version", version)
+	if err := d.run(ctx, output, "--repo", d.repos.repoOf(packageName), "downgrade", "-y", packageName+"-"+version); err != nil {
+		d.logger.Error("Failed to downgrade package", err, "pkg", packageName, "version", version)
+		return fmt.Errorf("failed to downgrade package %s-%s: %w", packageName, version, err)
+	}
+
+	d.logger.Info("Package downgraded successfully", "pkg", packageName, "version", version)
+	return nil
+}
+
+func (d *DnfManager) ListVersions(ctx context.Context, packageName string) ([]PackageVersion, error) {
+	available, err := d.availableVersions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed string
+	if pkg, err := d.GetInstalledPackage(ctx, packageName); err == nil {
+		installed = pkg.InstalledVersion
+	}
+
+	return buildVersionList(available, installed, CompareRPMVersions), nil
+}
+
+func (d *DnfManager) UpdateRepository(ctx context.Context) error {
+	args := []string{d.binary}
+	for _, repoID := range d.repos.reposInUse() {
+		args = append(args, "--repo", repoID)
+	}
+
+	out, err := exec.CommandContext(ctx, "sudo", append(args, "makecache", "--refresh")...).CombinedOutput() //nolint:gosec
+	if err != nil {
+		d.logger.Error("Failed to refresh repository metadata", err, "out", string(out))
+		return fmt.Errorf("failed to refresh repository metadata: %w", err)
+	}
+
+	d.logger.Info("Package list updated successfully")
+	d.logger.Debug("Output: %s", string(out))
+	return nil
+}
+
+func (d *DnfManager) SetChannel(ctx context.Context, packageName string, channel Channel) error {
+	return d.repos.setChannel(ctx, packageName, channel)
+}
+
+func (d *DnfManager) SimulateUpgrade(ctx context.Context, packageName, version string) (UpgradePlan, error) {
+	args := []string{d.binary, "--repo", d.repos.repoOf(packageName)}
+	if version != "" {
+		fullVersion, err := d.resolveVersion(ctx, packageName, version)
+		if err != nil {
+			return UpgradePlan{}, err
+		}
+		args = append(args, "install", "--assumeno", packageName+"-"+fullVersion)
+	} else {
+		args = append(args, "upgrade", "--assumeno", packageName)
+	}
+
+	// dnf exits with an error when the transaction is declined, the output tells it apart from real failures
+	out, err := exec.CommandContext(ctx, "sudo", args...).CombinedOutput() //nolint:gosec
+	if err != nil && !bytes.Contains(out, []byte("Operation aborted")) && !bytes.Contains(out, []byte("Exiting on user command")) {
+		d.logger.Error("Failed to simulate upgrade", err, "pkg", packageName, "out", string(out))
+		return UpgradePlan{}, fmt.Errorf("failed to simulate upgrade of %s: %w, output: %s", packageName, err, string(out))
+	}
+
+	changes := parseDnfTransaction(out)
+	// dnf4 prints only the new versions
+	if pkg, err := d.GetInstalledPackage(ctx, packageName); err == nil {
+		for i := range changes {
+			if changes[i].Name == packageName && changes[i].CurrentVersion == "" {
+				changes[i].CurrentVersion = pkg.InstalledVersion
+			}
+		}
+	}
+
+	return UpgradePlan{
+		Package: packageName,
+		Version: version,
+		Changes: changes,
+	}, nil
+}
+
+// availableVersions returns every version-release of the package offered by the dvnet repository.
+func (d *DnfManager) availableVersions(ctx context.Context, packageName string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, d.binary, "--repo", d.repos.repoOf(packageName), "repoquery", "--available", "--queryformat", rpmQueryFormat, packageName).Output() //nolint:gosec
+	if err != nil {
+		d.logger.Error("Failed to list package versions", err, "pkg", packageName)
+		return nil, fmt.Errorf("failed to list versions of %s: %w", packageName, err)
+	}
+
+	return parseQueryFormatOutput(out), nil
+}
+
+func (d *DnfManager) resolveVersion(ctx context.Context, packageName, version string) (string, error) {
+	versions, err := d.availableVersions(ctx, packageName)
+	if err != nil {
+		return "", err
+	}
+
+	fullVersion, ok := matchVersion(versions, version)
+	if !ok {
+		return "", fmt.Errorf("%w: %s-%s", ErrVersionNotFound, packageName, version)
+	}
+
+	return fullVersion, nil
+}
+
+func (d *DnfManager) run(ctx context.Context, output io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "sudo", append([]string{d.binary}, args...)...) //nolint:gosec
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	return cmd.Run()
+}
+
+// parseDnfTransaction parses the transaction table of dnf and dnf5. dnf5 lists the replaced
+// version below the new one, it becomes the current version of the change:
+//
+//	Upgrading:
+//	 dv-merchant         x86_64   1.4.2-1   dvnet      10.0 MiB
+//	   replacing dv-merchant x86_64 1.4.1-1 <unknown>  9.8 MiB
+func parseDnfTransaction(out []byte) []PlannedChange {
+	changes := make([]PlannedChange, 0)
+
+	var action Action
+	var wrapped string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") {
+			action = yumSectionAction(line)
+			wrapped = ""
+			continue
+		}
+
+		if action == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if fields[0] == "replacing" {
+			if last := len(changes) - 1; last >= 0 && len(fields) >= 4 && changes[last].Name == fields[1] {
+				changes[last].CurrentVersion = fields[3]
+			}
+			continue
+		}
+
+		// long package names are printed on a line of their own
+		if wrapped != "" {
+			fields = append([]string{wrapped}, fields...)
+			wrapped = ""
+		}
+		if len(fields) == 1 {
+			wrapped = fields[0]
+			continue
+		}
+		if len(fields) < 4 {
+			continue
+		}
+
+		change := PlannedChange{Name: fields[0], Action: action, Version: fields[2], Repository: fields[3]}
+		if action == ActionRemove {
+			change.CurrentVersion, change.Version = change.Version, ""
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
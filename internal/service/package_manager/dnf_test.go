@@ -0,0 +1,104 @@
+package package_manager
+
+import "testing"
+
+func TestParseDnfTransaction(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []PlannedChange
+	}{
+		{
+			name: "dnf",
+			out: `Last metadata expiration check: 0:12:03 ago on Tue 10 Mar 2026 02:48:11 AM UTC.
+Dependencies resolved.
+================================================================================
+ Package                         Arch       Version       Repository       Size
+================================================================================
+Upgrading:
+ dv-merchant                     x86_64     1.4.2-1       dvnet            10 M
+Installing dependencies:
+ dv-merchant-migrations-postgresql
+                                 noarch     1.4.2-1       dvnet           1.2 M
+Removing dependent packages:
+ dv-legacy                       x86_64     0.1.0-1       @dvnet          200 k
+
+Transaction Summary
+================================================================================
+Install  1 Package
+Upgrade  1 Package
+Remove   1 Package
+
+Total download size: 11 M
+Operation aborted.
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionUpgrade, Version: "1.4.2-1", Repository: "dvnet"},
+				{Name: "dv-merchant-migrations-postgresql", Action: ActionInstall, Version: "1.4.2-1", Repository: "dvnet"},
+				{Name: "dv-legacy", Action: ActionRemove, CurrentVersion: "0.1.0-1", Repository: "@dvnet"},
+			},
+		},
+		{
+			name: "dnf downgrade",
+			out: `Last metadata expiration check: 0:01:10 ago on Tue 10 Mar 2026 03:00:02 AM UTC.
+Dependencies resolved.
+================================================================================
+ Package             Arch           Version           Repository           Size
+================================================================================
+Downgrading:
+ dv-merchant         x86_64         1.4.1-1           dvnet               9.8 M
+
+Transaction Summary
+================================================================================
+Downgrade  1 Package
+
+Total download size: 9.8 M
+Operation aborted.
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionDowngrade, Version: "1.4.1-1", Repository: "dvnet"},
+			},
+		},
+		{
+			name: "dnf5 with replaced versions and repository progress",
+			out: `Updating and loading repositories:
+ dvnet                                  100% |  27.9 KiB/s |   3.9 KiB |  00m00s
+Repositories loaded.
+Package                        Arch   Version          Repository          Size
+Upgrading:
+ dv-merchant                   x86_64 1.4.2-1          dvnet           10.0 MiB
+   replacing dv-merchant       x86_64 1.4.1-1          dvnet            9.8 MiB
+Installing dependencies:
+ dv-merchant-migrations        noarch 1.4.2-1          dvnet            1.2 MiB
+
+Transaction Summary:
+ Installing:         1 package
+ Upgrading:          1 package
+ Replacing:          1 package
+
+Total size of inbound packages is 11 MiB. Need to download 11 MiB.
+After this operation, 1 MiB extra will be used (install 11 MiB, remove 10 MiB).
+Operation aborted by the user.
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionUpgrade, CurrentVersion: "1.4.1-1", Version: "1.4.2-1", Repository: "dvnet"},
+				{Name: "dv-merchant-migrations", Action: ActionInstall, Version: "1.4.2-1", Repository: "dvnet"},
+			},
+		},
+		{
+			name: "nothing to do",
+			out: `Last metadata expiration check: 0:12:03 ago on Tue 10 Mar 2026 02:48:11 AM UTC.
+Dependencies resolved.
+Nothing to do.
+Complete!
+`,
+			want: []PlannedChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertChanges(t, parseDnfTransaction([]byte(tt.out)), tt.want)
+		})
+	}
+}
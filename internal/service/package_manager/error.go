@@ -4,4 +4,7 @@ import "errors"
 
 var (
 	ErrNothingToUpdate = errors.New("nothing to update")
+	ErrVersionNotFound = errors.New("version not found in repository")
+
+	ErrChannelsNotConfigured = errors.New("repository is not configured for release channels")
 )
@@ -0,0 +1,144 @@
+package package_manager
+
+import (
+	"regexp"
+	"strings"
+)
+
+type Action string
+
+const (
+	ActionInstall   Action = "install"
+	ActionUpgrade   Action = "upgrade"
+	ActionDowngrade Action = "downgrade"
+	ActionReinstall Action = "reinstall"
+	ActionRemove    Action = "remove"
+)
+
+// PlannedChange is a single package change the package manager would make.
+type PlannedChange struct {
+	Name           string `json:"name"`
+	Action         Action `json:"action"`
+	CurrentVersion string `json:"current_version,omitempty"`
+	Version        string `json:"version,omitempty"`
+	Repository     string `json:"repository,omitempty"`
+}
+
+// UpgradePlan describes what an upgrade would do without executing it.
+type UpgradePlan struct {
+	Package string          `json:"package"`
+	Version string          `json:"version,omitempty"`
+	Changes []PlannedChange `json:"changes"`
+}
+
+// Changed reports whether the plan changes anything at all.
+func (p UpgradePlan) Changed() bool {
+	return len(p.Changes) > 0
+}
+
+var (
+	// aptInstRe matches lines like "Inst dv-merchant [1.4.1] (1.4.2 dvnet:stable [amd64])".
+	aptInstRe = regexp.MustCompile(`^Inst (\S+)(?: \[(\S+)\])? \((\S+)(?: ([^\[)]+?))?(?: \[[^\]]*\])?\)`)
+	// aptRemvRe matches lines like "Remv libfoo [1.0-1]".
+	aptRemvRe = regexp.MustCompile(`^Remv (\S+)(?: \[(\S+)\])?`)
+)
+
+// parseAptSimulation parses the output of "apt-get -s".
+func parseAptSimulation(out []byte) []PlannedChange {
+	changes := make([]PlannedChange, 0)
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := aptInstRe.FindStringSubmatch(line); m != nil {
+			change := PlannedChange{Name: m[1], CurrentVersion: m[2], Version: m[3], Repository: m[4]}
+			switch c := CompareDebianVersions(change.Version, change.CurrentVersion); {
+			case change.CurrentVersion == "":
+				change.Action = ActionInstall
+			case c > 0:
+				change.Action = ActionUpgrade
+			case c < 0:
+				change.Action = ActionDowngrade
+			default:
+				change.Action = ActionReinstall
+			}
+			changes = append(changes, change)
+			continue
+		}
+
+		if m := aptRemvRe.FindStringSubmatch(line); m != nil {
+			changes = append(changes, PlannedChange{Name: m[1], Action: ActionRemove, CurrentVersion: m[2]})
+		}
+	}
+
+	return changes
+}
+
+// parseYumTransaction parses the transaction table yum and dnf print before asking for confirmation:
+//
+//	Upgrading:
+//	 dv-merchant      x86_64      1.4.2-1      dvnet      10 M
+func parseYumTransaction(out []byte) []PlannedChange {
+	changes := make([]PlannedChange, 0)
+
+	var action Action
+	var wrapped string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") {
+			action = yumSectionAction(line)
+			wrapped = ""
+			continue
+		}
+
+		if action == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		// long package names are printed on a line of their own
+		if wrapped != "" {
+			fields = append([]string{wrapped}, fields...)
+			wrapped = ""
+		}
+		if len(fields) == 1 {
+			wrapped = fields[0]
+			continue
+		}
+		if len(fields) < 4 || fields[0] == "replacing" {
+			continue
+		}
+
+		change := PlannedChange{Name: fields[0], Action: action, Version: fields[2], Repository: fields[3]}
+		if action == ActionRemove {
+			change.CurrentVersion, change.Version = change.Version, ""
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// yumSectionRe matches headers of the transaction table sections like "Installing for dependencies:"
+// or "Removing dependent packages:", but not "Updating and loading repositories:" of dnf5.
+var yumSectionRe = regexp.MustCompile(`^(Installing|Upgrading|Updating|Downgrading|Reinstalling|Removing|Erasing)( [a-z ]*(dependencies|packages))?:$`)
+
+func yumSectionAction(header string) Action {
+	m := yumSectionRe.FindStringSubmatch(strings.TrimSpace(header))
+	if m == nil {
+		return ""
+	}
+
+	switch m[1] {
+	case "Installing":
+		return ActionInstall
+	case "Upgrading", "Updating":
+		return ActionUpgrade
+	case "Downgrading":
+		return ActionDowngrade
+	case "Reinstalling":
+		return ActionReinstall
+	default:
+		return ActionRemove
+	}
+}
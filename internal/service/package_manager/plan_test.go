@@ -0,0 +1,191 @@
+package package_manager
+
+import (
+	"slices"
+	"testing"
+)
+
+func assertChanges(t *testing.T, got, want []PlannedChange) {
+	t.Helper()
+
+	if !slices.Equal(got, want) {
+		t.Errorf("changes mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestParseAptSimulation(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []PlannedChange
+	}{
+		{
+			name: "upgrade",
+			out: `NOTE: This is only a simulation!
+      apt-get needs root privileges for real execution.
+      Keep also in mind that locking is deactivated,
+      so don't depend on the relevance to the real current situation!
+Reading package lists...
+Building dependency tree...
+Reading state information...
+The following packages will be upgraded:
+  dv-merchant
+1 upgraded, 0 newly installed, 0 to remove and 12 not upgraded.
+Inst dv-merchant [1.4.1] (1.4.2 dvnet:stable [amd64])
+Conf dv-merchant (1.4.2 dvnet:stable [amd64])
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionUpgrade, CurrentVersion: "1.4.1", Version: "1.4.2", Repository: "dvnet:stable"},
+			},
+		},
+		{
+			name: "dependencies, removals and security updates",
+			out: `Reading package lists...
+Building dependency tree...
+Reading state information...
+The following packages will be REMOVED:
+  dv-legacy
+The following NEW packages will be installed:
+  dv-merchant-migrations
+The following packages will be upgraded:
+  dv-merchant libssl3
+2 upgraded, 1 newly installed, 1 to remove and 10 not upgraded.
+Remv dv-legacy [0.1.0]
+Inst libssl3 [3.0.11-1~deb12u2] (3.0.13-1~deb12u1 Debian-Security:12/stable-security [amd64]) []
+Inst dv-merchant-migrations (1.4.2 dvnet:stable [all])
+Inst dv-merchant [1.4.1] (1.4.2 dvnet:stable [amd64])
+Conf libssl3 (3.0.13-1~deb12u1 Debian-Security:12/stable-security [amd64])
+Conf dv-merchant-migrations (1.4.2 dvnet:stable [all])
+Conf dv-merchant (1.4.2 dvnet:stable [amd64])
+`,
+			want: []PlannedChange{
+				{Name: "dv-legacy", Action: ActionRemove, CurrentVersion: "0.1.0"},
+				{Name: "libssl3", Action: ActionUpgrade, CurrentVersion: "3.0.11-1~deb12u2", Version: "3.0.13-1~deb12u1", Repository: "Debian-Security:12/stable-security"},
+				{Name: "dv-merchant-migrations", Action: ActionInstall, Version: "1.4.2", Repository: "dvnet:stable"},
+				{Name: "dv-merchant", Action: ActionUpgrade, CurrentVersion: "1.4.1", Version: "1.4.2", Repository: "dvnet:stable"},
+			},
+		},
+		{
+			name: "downgrade and reinstall",
+			out: `Reading package lists...
+Building dependency tree...
+Reading state information...
+The following packages will be DOWNGRADED:
+  dv-merchant
+0 upgraded, 0 newly installed, 1 downgraded, 1 reinstalled, 0 to remove and 0 not upgraded.
+Inst dv-merchant [1.4.2] (1.4.2~rc1 dvnet:rc [amd64])
+Inst dv-processing [0.9.3] (0.9.3 dvnet:stable [amd64])
+Conf dv-merchant (1.4.2~rc1 dvnet:rc [amd64])
+Conf dv-processing (0.9.3 dvnet:stable [amd64])
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionDowngrade, CurrentVersion: "1.4.2", Version: "1.4.2~rc1", Repository: "dvnet:rc"},
+				{Name: "dv-processing", Action: ActionReinstall, CurrentVersion: "0.9.3", Version: "0.9.3", Repository: "dvnet:stable"},
+			},
+		},
+		{
+			name: "nothing to do",
+			out: `Reading package lists...
+Building dependency tree...
+Reading state information...
+dv-merchant is already the newest version (1.4.2).
+0 upgraded, 0 newly installed, 0 to remove and 12 not upgraded.
+`,
+			want: []PlannedChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertChanges(t, parseAptSimulation([]byte(tt.out)), tt.want)
+		})
+	}
+}
+
+func TestParseYumTransaction(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []PlannedChange
+	}{
+		{
+			name: "yum upgrade with a wrapped dependency",
+			out: `Loaded plugins: fastestmirror
+Loading mirror speeds from cached hostfile
+Resolving Dependencies
+--> Running transaction check
+---> Package dv-merchant.x86_64 0:1.4.1-1 will be updated
+---> Package dv-merchant.x86_64 0:1.4.2-1 will be an update
+---> Package dv-merchant-migrations-postgresql.noarch 0:1.4.2-1 will be installed
+--> Finished Dependency Resolution
+
+Dependencies Resolved
+
+================================================================================
+ Package                           Arch       Version       Repository     Size
+================================================================================
+Updating:
+ dv-merchant                       x86_64     1.4.2-1       dvnet          10 M
+Installing for dependencies:
+ dv-merchant-migrations-postgresql
+                                   noarch     1.4.2-1       dvnet         1.2 M
+
+Transaction Summary
+================================================================================
+Install       ( 1 Dependent package)
+Upgrade  1 Package
+
+Total download size: 11 M
+Exiting on user command
+Your transaction was saved, rerun it with:
+ yum load-transaction /tmp/yum_save_tx.2026-03-10.03-00.Xk2n1f.yumtx
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionUpgrade, Version: "1.4.2-1", Repository: "dvnet"},
+				{Name: "dv-merchant-migrations-postgresql", Action: ActionInstall, Version: "1.4.2-1", Repository: "dvnet"},
+			},
+		},
+		{
+			name: "yum downgrade and removal",
+			out: `Loaded plugins: fastestmirror
+Resolving Dependencies
+--> Finished Dependency Resolution
+
+Dependencies Resolved
+
+================================================================================
+ Package          Arch         Version          Repository               Size
+================================================================================
+Downgrading:
+ dv-merchant      x86_64       1.4.1-1          dvnet                   9.8 M
+Removing for dependencies:
+ dv-legacy        x86_64       0.1.0-1          @dvnet                  200 k
+
+Transaction Summary
+================================================================================
+Remove                 ( 1 Dependent package)
+Downgrade  1 Package
+
+Exiting on user command
+`,
+			want: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionDowngrade, Version: "1.4.1-1", Repository: "dvnet"},
+				{Name: "dv-legacy", Action: ActionRemove, CurrentVersion: "0.1.0-1", Repository: "@dvnet"},
+			},
+		},
+		{
+			name: "nothing to do",
+			out: `Loaded plugins: fastestmirror
+Loading mirror speeds from cached hostfile
+No packages marked for update
+`,
+			want: []PlannedChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertChanges(t, parseYumTransaction([]byte(tt.out)), tt.want)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package package_manager
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/pkg/logger"
+)
+
+const (
+	yumRepo         = "dvnet"
+	yumReposPath    = "/etc/yum.repos.d/dvnet.repo"
+	zypperReposPath = "/etc/zypp/repos.d/dvnet.repo"
+	channelToken    = "{channel}"
+
+	// rpmQueryFormat prints version-release per line, dnf5 does not end query lines by itself
+	rpmQueryFormat = "%{version}-%{release}\n"
+)
+
+// rpmRepos manages the dvnet repositories in the repo file, yum and dnf share yum.repos.d while
+// zypper reads the same format from zypp/repos.d.
+type rpmRepos struct {
+	logger   logger.Logger
+	repoConf config.RepositoryConfig
+	path     string
+
+	mu       sync.RWMutex
+	channels map[string]Channel
+}
+
+func newRPMRepos(l logger.Logger, repoConf config.RepositoryConfig, path string) *rpmRepos {
+	return &rpmRepos{
+		logger:   l,
+		repoConf: repoConf,
+		path:     path,
+		channels: make(map[string]Channel),
+	}
+}
+
+// setChannel makes the package follow the release channel. The dvnet repo file is rewritten to
+// define a repository for every channel in use, non-stable ones are disabled unless requested explicitly.
+func (r *rpmRepos) setChannel(ctx context.Context, packageName string, channel Channel) error {
+	if err := channel.Validate(); err != nil {
+		return err
+	}
+
+	if r.repoConf.YumURL == "" {
+		if channel != ChannelStable {
+			return ErrChannelsNotConfigured
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.channels[packageName] = channel
+
+	var repos strings.Builder
+	for _, c := range channelsInUse(r.channels) {
+		enabled := 0
+		if c == ChannelStable {
+			enabled = 1
+		}
+
+		_, _ = fmt.Fprintf(&repos, "[%s]\nname=dvnet %s\nbaseurl=%s\nenabled=%d\n", yumRepoID(c), c, strings.ReplaceAll(r.repoConf.YumURL, channelToken, string(c)), enabled)
+		if r.repoConf.YumGPGKey != "" {
+			_, _ = fmt.Fprintf(&repos, "gpgcheck=1\ngpgkey=%s\n", r.repoConf.YumGPGKey)
+		} else {
+			repos.WriteString("gpgcheck=0\n")
+		}
+		repos.WriteString("\n")
+	}
+
+	cmd := exec.CommandContext(ctx, "sudo", "tee", r.path)
+	cmd.Stdin = strings.NewReader(repos.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		r.logger.Error("Failed to write rpm repositories", err, "out", string(out))
+		return fmt.Errorf("failed to write %s: %w", r.path, err)
+	}
+
+	r.logger.Info("Package release channel changed", "pkg", packageName, "channel", channel)
+	return nil
+}
+
+func (r *rpmRepos) repoOf(packageName string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c, ok := r.channels[packageName]; ok {
+		return yumRepoID(c)
+	}
+
+	return yumRepo
+}
+
+func (r *rpmRepos) reposInUse() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	channels := channelsInUse(r.channels)
+	repos := make([]string, 0, len(channels))
+	for _, c := range channels {
+		repos = append(repos, yumRepoID(c))
+	}
+
+	return repos
+}
+
+// installedRPMPackage queries the rpm database, the highest version wins when several are installed.
+func installedRPMPackage(ctx context.Context, l logger.Logger, packageName string) (Package, error) {
+	out, err := exec.CommandContext(ctx, "rpm", "-q", "--queryformat", rpmQueryFormat, packageName).Output()
+	if err != nil {
+		l.Error("Failed to get installed package", err, "pkg", packageName, "out", string(out))
+		return Package{}, fmt.Errorf("package %s is not installed: %w", packageName, err)
+	}
+
+	installed := latestRPMVersion(parseQueryFormatOutput(out))
+	if installed == "" {
+		return Package{}, fmt.Errorf("package %s is not installed", packageName)
+	}
+
+	return Package{
+		Name:             packageName,
+		InstalledVersion: installed,
+		AvailableVersion: installed,
+	}, nil
+}
+
+func yumRepoID(channel Channel) string {
+	if channel == ChannelStable {
+		return yumRepo
+	}
+
+	return yumRepo + "-" + string(channel)
+}
+
+// parseQueryFormatOutput returns the unique non-empty lines printed for rpmQueryFormat.
+func parseQueryFormatOutput(out []byte) []string {
+	var versions []string
+	seen := make(map[string]struct{})
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if _, ok := seen[line]; ok {
+			continue
+		}
+		seen[line] = struct{}{}
+		versions = append(versions, line)
+	}
+
+	return versions
+}
+
+func latestRPMVersion(versions []string) string {
+	var latest string
+	for _, v := range versions {
+		if latest == "" || CompareRPMVersions(v, latest) > 0 {
+			latest = v
+		}
+	}
+
+	return latest
+}
@@ -1,12 +1,22 @@
 package package_manager
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 type PackageManager interface {
 	GetInstalledPackage(ctx context.Context, packageName string) (Package, error)
 	CheckForUpdates(ctx context.Context, packageName string) (Package, error)
-	UpgradePackage(ctx context.Context, packageName string) error
+	UpgradePackage(ctx context.Context, packageName string, output io.Writer) error
+	InstallPackage(ctx context.Context, packageName, version string, output io.Writer) error
+	DowngradePackage(ctx context.Context, packageName, version string, output io.Writer) error
+	ListVersions(ctx context.Context, packageName string) ([]PackageVersion, error)
 	UpdateRepository(ctx context.Context) error
+	SetChannel(ctx context.Context, packageName string, channel Channel) error
+	// SimulateUpgrade returns what upgrading the package would change without changing anything.
+	// An empty version means the latest available one.
+	SimulateUpgrade(ctx context.Context, packageName, version string) (UpgradePlan, error)
 }
 
 type Package struct {
@@ -15,3 +25,8 @@ type Package struct {
 	AvailableVersion string `json:"available_version"`
 	NeedForUpdate    bool   `json:"need_for_update"`
 }
+
+// UpgradesToPreRelease reports whether the available version would move a stable installation to a pre-release build.
+func (p Package) UpgradesToPreRelease() bool {
+	return IsPreRelease(p.AvailableVersion) && !IsPreRelease(p.InstalledVersion)
+}
@@ -0,0 +1,407 @@
+package package_manager
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type PackageVersion struct {
+	Version   string `json:"version"`
+	Installed bool   `json:"installed"`
+}
+
+// VersionComparator returns a negative number when a < b, zero when they are equal and a positive number otherwise.
+type VersionComparator func(a, b string) int
+
+// preReleaseRe matches RC, beta, nightly and similar builds, including the tilde form used by deb and rpm.
+var preReleaseRe = regexp.MustCompile(`(?i)(~|(^|[.\-+_]|\d)(rc|alpha|beta|pre|dev|nightly)(\d|[.\-+_]|$))`)
+
+// IsPreRelease reports whether the version is not a stable build.
+func IsPreRelease(version string) bool {
+	return preReleaseRe.MatchString(version)
+}
+
+// sortVersions orders versions from the newest to the oldest.
+func sortVersions(versions []PackageVersion, compare VersionComparator) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compare(versions[i].Version, versions[j].Version) > 0
+	})
+}
+
+// buildVersionList merges repository versions with the installed one and marks the latter.
+func buildVersionList(available []string, installed string, compare VersionComparator) []PackageVersion {
+	versions := make([]PackageVersion, 0, len(available)+1)
+	seen := make(map[string]struct{}, len(available))
+	for _, v := range available {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		versions = append(versions, PackageVersion{Version: v, Installed: v == installed})
+	}
+
+	if _, ok := seen[installed]; !ok && installed != "" {
+		versions = append(versions, PackageVersion{Version: installed, Installed: true})
+	}
+
+	sortVersions(versions, compare)
+	return versions
+}
+
+// needForUpdate reports whether available is newer than installed.
+func needForUpdate(installed, available string, compare VersionComparator) bool {
+	return installed != "" && available != "" && compare(available, installed) > 0
+}
+
+// matchVersion accepts both a full version-release ("1.4.2-1", "1.4.2-r0") and a bare version ("1.4.2").
+func matchVersion(versions []string, version string) (string, bool) {
+	for _, v := range versions {
+		if v == version || strings.SplitN(v, "-", 2)[0] == version {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// CompareDebianVersions compares [epoch:]upstream[-revision] versions the way dpkg does.
+func CompareDebianVersions(a, b string) int {
+	epochA, upstreamA, revisionA := splitDebianVersion(a)
+	epochB, upstreamB, revisionB := splitDebianVersion(b)
+
+	if c := compareNumeric(epochA, epochB); c != 0 {
+		return c
+	}
+	if c := debianVerRevCmp(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+
+	return debianVerRevCmp(revisionA, revisionB)
+}
+
+func splitDebianVersion(v string) (string, string, string) {
+	epoch := "0"
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		epoch, v = v[:i], v[i+1:]
+	}
+
+	revision := ""
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		v, revision = v[:i], v[i+1:]
+	}
+
+	return epoch, v, revision
+}
+
+// debianOrder weights a character, tilde sorts before everything including the end of the string.
+func debianOrder(c byte) int {
+	switch {
+	case isDigit(c):
+		return 0
+	case isAlpha(c):
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func debianVerRevCmp(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			var ac, bc int
+			if a != "" {
+				ac = debianOrder(a[0])
+			}
+			if b != "" {
+				bc = debianOrder(b[0])
+			}
+			if ac != bc {
+				return ac - bc
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		var digitsA, digitsB string
+		digitsA, a = splitDigits(a)
+		digitsB, b = splitDigits(b)
+		if c := compareNumeric(digitsA, digitsB); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// CompareRPMVersions compares [epoch:]version[-release] versions the way rpm does.
+func CompareRPMVersions(a, b string) int {
+	epochA, versionA, releaseA := splitRPMVersion(a)
+	epochB, versionB, releaseB := splitRPMVersion(b)
+
+	if c := compareNumeric(epochA, epochB); c != 0 {
+		return c
+	}
+	if c := rpmVerCmp(versionA, versionB); c != 0 {
+		return c
+	}
+
+	// rpm ignores the release when one of the sides does not specify it
+	if releaseA == "" || releaseB == "" {
+		return 0
+	}
+
+	return rpmVerCmp(releaseA, releaseB)
+}
+
+func splitRPMVersion(v string) (string, string, string) {
+	epoch := "0"
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		epoch, v = v[:i], v[i+1:]
+	}
+
+	release := ""
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		v, release = v[:i], v[i+1:]
+	}
+
+	return epoch, v, release
+}
+
+func rpmVerCmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for a != "" || b != "" {
+		a = strings.TrimLeftFunc(a, isRPMSeparator)
+		b = strings.TrimLeftFunc(b, isRPMSeparator)
+
+		// tilde sorts before everything
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// caret sorts after the end of the string but before anything else
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			switch {
+			case a == "":
+				return -1
+			case b == "":
+				return 1
+			case !strings.HasPrefix(a, "^"):
+				return 1
+			case !strings.HasPrefix(b, "^"):
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		var segA, segB string
+		numeric := isDigit(a[0])
+		if numeric {
+			segA, a = splitDigits(a)
+			segB, b = splitDigits(b)
+		} else {
+			segA, a = splitAlpha(a)
+			segB, b = splitAlpha(b)
+		}
+
+		// numeric segments are always newer than alpha ones
+		if segB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		var c int
+		if numeric {
+			c = compareNumeric(segA, segB)
+		} else {
+			c = strings.Compare(segA, segB)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+func isRPMSeparator(r rune) bool {
+	return r < 128 && !isDigit(byte(r)) && !isAlpha(byte(r)) && r != '~' && r != '^'
+}
+
+// CompareAPKVersions compares number{.number}[letter]{_suffix[number]}[-rN] versions the way apk does.
+func CompareAPKVersions(a, b string) int {
+	versionA, revisionA := splitAPKVersion(a)
+	versionB, revisionB := splitAPKVersion(b)
+
+	tokensA, tokensB := apkTokens(versionA), apkTokens(versionB)
+	for i := 0; i < len(tokensA) || i < len(tokensB); i++ {
+		ta, tb := apkToken{kind: apkEnd}, apkToken{kind: apkEnd}
+		if i < len(tokensA) {
+			ta = tokensA[i]
+		}
+		if i < len(tokensB) {
+			tb = tokensB[i]
+		}
+
+		if ta.kind != tb.kind {
+			// pre-release suffixes sort before the end of the version, everything else after it
+			switch {
+			case ta.kind == apkSuffix && ta.rank < apkReleaseRank:
+				return -1
+			case tb.kind == apkSuffix && tb.rank < apkReleaseRank:
+				return 1
+			case ta.kind < tb.kind:
+				return 1
+			default:
+				return -1
+			}
+		}
+
+		if c := ta.compare(tb); c != 0 {
+			return c
+		}
+	}
+
+	return compareNumeric(revisionA, revisionB)
+}
+
+const (
+	apkDigit = iota
+	apkLetter
+	apkSuffix
+	apkEnd
+)
+
+// apkReleaseRank separates pre-release suffixes from post-release ones.
+const apkReleaseRank = 4
+
+var apkSuffixRanks = map[string]int{
+	"alpha": 0, "beta": 1, "pre": 2, "rc": 3,
+	"cvs": 5, "svn": 6, "git": 7, "hg": 8, "p": 9,
+}
+
+type apkToken struct {
+	kind  int
+	value string
+	rank  int
+}
+
+func (t apkToken) compare(o apkToken) int {
+	switch t.kind {
+	case apkDigit:
+		return compareNumeric(t.value, o.value)
+	case apkSuffix:
+		if t.rank != o.rank {
+			return t.rank - o.rank
+		}
+		return compareNumeric(t.value, o.value)
+	default:
+		return strings.Compare(t.value, o.value)
+	}
+}
+
+// splitAPKVersion cuts the -rN package release and the ~hash of VCS snapshots off the version.
+func splitAPKVersion(v string) (string, string) {
+	revision := "0"
+	if i := strings.LastIndex(v, "-r"); i >= 0 {
+		v, revision = v[:i], v[i+2:]
+	}
+	if i := strings.IndexByte(v, '~'); i >= 0 {
+		v = v[:i]
+	}
+
+	return v, revision
+}
+
+// apkTokens splits the version into its parts, anything after an invalid character is ignored.
+func apkTokens(v string) []apkToken {
+	var tokens []apkToken
+
+	digits, v := splitDigits(v)
+	tokens = append(tokens, apkToken{kind: apkDigit, value: digits})
+	for v != "" {
+		switch {
+		case v[0] == '.' && len(v) > 1 && isDigit(v[1]):
+			digits, v = splitDigits(v[1:])
+			tokens = append(tokens, apkToken{kind: apkDigit, value: digits})
+		case isAlpha(v[0]) && tokens[len(tokens)-1].kind == apkDigit:
+			tokens = append(tokens, apkToken{kind: apkLetter, value: v[:1]})
+			v = v[1:]
+		case v[0] == '_':
+			var name, number string
+			name, v = splitAlpha(v[1:])
+			number, v = splitDigits(v)
+			rank, ok := apkSuffixRanks[name]
+			if !ok {
+				return tokens
+			}
+			tokens = append(tokens, apkToken{kind: apkSuffix, value: number, rank: rank})
+		default:
+			return tokens
+		}
+	}
+
+	return tokens
+}
+
+// compareNumeric compares digit strings of any length.
+func compareNumeric(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+
+	return strings.Compare(a, b)
+}
+
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+
+	return s[:i], s[i:]
+}
+
+func splitAlpha(s string) (string, string) {
+	i := 0
+	for i < len(s) && isAlpha(s[i]) {
+		i++
+	}
+
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,180 @@
+package package_manager
+
+import "testing"
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type compareCase struct {
+	a, b string
+	want int
+}
+
+func testComparator(t *testing.T, compare VersionComparator, tests []compareCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := sign(compare(tt.a, tt.b)); got != tt.want {
+				t.Errorf("compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := sign(compare(tt.b, tt.a)); got != -tt.want {
+				t.Errorf("compare(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareDebianVersions(t *testing.T) {
+	testComparator(t, CompareDebianVersions, []compareCase{
+		{a: "1.4.2", b: "1.4.2", want: 0},
+		{a: "1.4.10", b: "1.4.9", want: 1},
+		{a: "1.4.2", b: "1.4.2.1", want: -1},
+		// tilde sorts before the end of the version
+		{a: "1.4.2~rc1", b: "1.4.2", want: -1},
+		{a: "1.4.2~rc1", b: "1.4.2~rc2", want: -1},
+		{a: "1.4.2~~", b: "1.4.2~", want: -1},
+		{a: "2.0.0~beta1-1", b: "2.0.0-1", want: -1},
+		// letters sort after the end, other characters after letters
+		{a: "1.4.2a", b: "1.4.2", want: 1},
+		{a: "1.4.2+b1", b: "1.4.2", want: 1},
+		{a: "1.4.2+b1", b: "1.4.2a", want: 1},
+		// epochs win over everything else
+		{a: "1:0.9", b: "2.0", want: 1},
+		{a: "0:1.4.2", b: "1.4.2", want: 0},
+		{a: "2:1.0", b: "10:1.0", want: -1},
+		// revisions
+		{a: "1.4.2-1", b: "1.4.2-2", want: -1},
+		{a: "1.4.2-10", b: "1.4.2-9", want: 1},
+		{a: "1.4.2", b: "1.4.2-1", want: -1},
+		{a: "1.4.2-1~deb12u1", b: "1.4.2-1", want: -1},
+		{a: "1.4.2-1ubuntu0.1", b: "1.4.2-1", want: 1},
+		{a: "1.0-2-1", b: "1.0-10", want: 1},
+	})
+}
+
+func TestCompareRPMVersions(t *testing.T) {
+	testComparator(t, CompareRPMVersions, []compareCase{
+		{a: "1.4.2", b: "1.4.2", want: 0},
+		{a: "1.4.10", b: "1.4.9", want: 1},
+		{a: "1.4.2", b: "1.4.2.1", want: -1},
+		{a: "1.4.2", b: "1_4_2", want: 0},
+		// tilde sorts before the end of the version
+		{a: "1.4.2~rc1", b: "1.4.2", want: -1},
+		{a: "1.4.2~rc1", b: "1.4.2~rc2", want: -1},
+		{a: "1.4.2~~", b: "1.4.2~", want: -1},
+		// caret sorts after the end of the version but before anything else
+		{a: "1.4.2^git1", b: "1.4.2", want: 1},
+		{a: "1.4.2^git1", b: "1.4.2.1", want: -1},
+		{a: "1.4.2^git1", b: "1.4.2^git2", want: -1},
+		{a: "1.4.2~rc1^git1", b: "1.4.2~rc1", want: 1},
+		{a: "1.4.2~rc1^git1", b: "1.4.2", want: -1},
+		// numeric segments are newer than alpha ones, alpha segments after the end
+		{a: "1.4.a", b: "1.4.1", want: -1},
+		{a: "1.4.2a", b: "1.4.2", want: 1},
+		{a: "1.4.2b", b: "1.4.2a", want: 1},
+		// epochs win over everything else
+		{a: "1:1.0", b: "2.0", want: 1},
+		{a: "0:1.4.2-1", b: "1.4.2-1", want: 0},
+		// releases, ignored when one side has none
+		{a: "1.4.2-1", b: "1.4.2-2", want: -1},
+		{a: "1.4.2-10", b: "1.4.2-9", want: 1},
+		{a: "1.4.2-1.el9", b: "1.4.2-1.el10", want: -1},
+		{a: "1.4.2", b: "1.4.2-5", want: 0},
+	})
+}
+
+func TestCompareAPKVersions(t *testing.T) {
+	testComparator(t, CompareAPKVersions, []compareCase{
+		{a: "1.4.2", b: "1.4.2", want: 0},
+		{a: "1.4.10", b: "1.4.9", want: 1},
+		{a: "1.4.2", b: "1.4.2.1", want: -1},
+		// -rN package releases
+		{a: "1.4.2-r0", b: "1.4.2-r1", want: -1},
+		{a: "1.4.2-r10", b: "1.4.2-r9", want: 1},
+		{a: "1.4.2", b: "1.4.2-r0", want: 0},
+		{a: "1.4.2-r5", b: "1.4.3-r0", want: -1},
+		{a: "1.4.2_rc1-r3", b: "1.4.2-r0", want: -1},
+		// pre-release suffixes sort before the end of the version, others after it
+		{a: "1.4.2_rc1", b: "1.4.2", want: -1},
+		{a: "1.4.2_rc1", b: "1.4.2_rc2", want: -1},
+		{a: "1.4.2_alpha1", b: "1.4.2_beta1", want: -1},
+		{a: "1.4.2_beta3", b: "1.4.2_rc1", want: -1},
+		{a: "1.4.2_p1", b: "1.4.2", want: 1},
+		{a: "1.4.2_git20260310", b: "1.4.2_p1", want: -1},
+		// a letter follows the last number
+		{a: "1.4.2a", b: "1.4.2", want: 1},
+		{a: "1.4.2b", b: "1.4.2a", want: 1},
+		// VCS snapshot hashes are ignored
+		{a: "1.4.2_git20260310~a1b2c3", b: "1.4.2_git20260310", want: 0},
+	})
+}
+
+func TestIsPreRelease(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "1.4.2", want: false},
+		{version: "1.4.2-1", want: false},
+		{version: "1:1.4.2-1", want: false},
+		{version: "1.4.2-1.el9", want: false},
+		{version: "1.4.2-r0", want: false},
+		{version: "1.4.2_p1-r0", want: false},
+		{version: "1.4.2-1ubuntu0.1", want: false},
+		{version: "1.4.2+develop", want: false},
+		{version: "1.4.2~rc1", want: true},
+		{version: "1.4.2~rc1-1", want: true},
+		{version: "1.4.2~git20260310", want: true},
+		{version: "1.4.2-rc1", want: true},
+		{version: "1.4.2rc1", want: true},
+		{version: "1.4.2-RC.1", want: true},
+		{version: "2.0.0-beta.1", want: true},
+		{version: "1.4.2.dev1", want: true},
+		{version: "1.4.2-nightly.20260310", want: true},
+		{version: "1.4.2_rc1-r0", want: true},
+		{version: "1.4.2_alpha2-r0", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if got := IsPreRelease(tt.version); got != tt.want {
+				t.Errorf("IsPreRelease(%q) = %v, want %v", tt.version, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	versions := []string{"1.4.2-1", "1.4.10-r0", "1.4.1-1"}
+
+	tests := []struct {
+		version string
+		want    string
+		wantOK  bool
+	}{
+		{version: "1.4.2-1", want: "1.4.2-1", wantOK: true},
+		{version: "1.4.2", want: "1.4.2-1", wantOK: true},
+		{version: "1.4.10", want: "1.4.10-r0", wantOK: true},
+		{version: "1.4.1", want: "1.4.1-1", wantOK: true},
+		{version: "1.4", want: "", wantOK: false},
+		{version: "1.4.2-2", want: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, ok := matchVersion(versions, tt.version)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("matchVersion(%q) = %q, %v, want %q, %v", tt.version, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -1,22 +1,30 @@
 package package_manager
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 
+	"github.com/dv-net/dv-updater/internal/config"
 	"github.com/dv-net/dv-updater/pkg/logger"
 )
 
 type YumManager struct {
 	logger logger.Logger
+	repos  *rpmRepos
 }
 
 var _ PackageManager = (*YumManager)(nil)
 
-func NewYumManager(log logger.Logger) *YumManager {
-	return &YumManager{logger: log}
+func NewYumManager(log logger.Logger, repoConf config.RepositoryConfig) *YumManager {
+	return &YumManager{
+		logger: log,
+		repos:  newRPMRepos(log, repoConf, yumReposPath),
+	}
 }
 
 func (y *YumManager) GetInstalledPackage(ctx context.Context, packageName string) (Package, error) {
@@ -24,15 +32,15 @@ func (y *YumManager) GetInstalledPackage(ctx context.Context, packageName string
 	out, err := exec.CommandContext(ctx, "sudo", "yum", "list", "installed", packageName).Output()
 	if err != nil {
 		y.logger.Error("Failed to get installed package: %v", err)
-		return Package{}, errors.New("package not found")
+		return Package{}, fmt.Errorf("package %s not found: %w", packageName, err)
 	}
 
 	return y.parseYumOutput(out, packageName)
 }
 
 func (y *YumManager) CheckForUpdates(ctx context.Context, packageName string) (Package, error) {
-	// sudo yum --repo=dvnet list --refresh
-	out, err := exec.CommandContext(ctx, "sudo", "yum", "--repo=dvnet", "list", "--refresh", packageName).Output()
+	// sudo yum --repo=dvnet list, the metadata is refreshed by UpdateRepository
+	out, err := exec.CommandContext(ctx, "sudo", "yum", "--repo="+y.repos.repoOf(packageName), "list", packageName).Output()
 	if err != nil {
 		y.logger.Error("Failed to check for updates: %v", err)
 		return Package{}, ErrNothingToUpdate
@@ -41,12 +49,17 @@ func (y *YumManager) CheckForUpdates(ctx context.Context, packageName string) (P
 	return y.parseYumOutput(out, packageName)
 }
 
-func (y *YumManager) UpgradePackage(ctx context.Context, packageName string) error {
+func (y *YumManager) UpgradePackage(ctx context.Context, packageName string, output io.Writer) error {
 	y.logger.Info("start Updating repository")
-	out, err := exec.CommandContext(ctx, "sudo", "yum", "--repo", "dvnet", "update", "-y", packageName).Output()
+	buf := new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, "sudo", "yum", "--repo", y.repos.repoOf(packageName), "update", "-y", packageName)
+	cmd.Stdout = io.MultiWriter(buf, output)
+	cmd.Stderr = output
+	err := cmd.Run()
+	out := buf.Bytes()
 	if err != nil {
 		y.logger.Error("Failed to update package: %s", err)
-		return errors.New("failed to update package")
+		return fmt.Errorf("failed to update package %s: %w", packageName, err)
 	}
 
 	y.logger.Info("Package %s updated successfully", packageName)
@@ -55,13 +68,95 @@ func (y *YumManager) UpgradePackage(ctx context.Context, packageName string) err
 	return nil
 }
 
+func (y *YumManager) InstallPackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	versions, err := y.availableVersions(ctx, packageName)
+	if err != nil {
+		return err
+	}
+
+	fullVersion, ok := matchVersion(versions, version)
+	if !ok {
+		return fmt.Errorf("%w: %s-%s", ErrVersionNotFound, packageName, version)
+	}
+
+	// yum install of an older version exits 0 without changing anything
+	if pkg, err := y.GetInstalledPackage(ctx, packageName); err == nil && CompareRPMVersions(fullVersion, pkg.InstalledVersion) < 0 {
+		if err = y.DowngradePackage(ctx, packageName, fullVersion, output); err != nil {
+			return err
+		}
+		return y.ensureInstalled(ctx, packageName, fullVersion)
+	}
+
+	y.logger.Info("Attempting to install package version", "pkg", packageName, "version", fullVersion)
+	cmd := exec.CommandContext(ctx, "sudo", "yum", "--repo", y.repos.repoOf(packageName), "install", "-y", packageName+"-"+fullVersion)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		y.logger.Error("Failed to install package version", err, "pkg", packageName, "version", fullVersion)
+		return fmt.Errorf("failed to install package %s-%s: %w", packageName, fullVersion, err)
+	}
+
+	if err := y.ensureInstalled(ctx, packageName, fullVersion); err != nil {
+		return err
+	}
+
+	y.logger.Info("Package version installed successfully", "pkg", packageName, "version", fullVersion)
+	return nil
+}
+
+// ensureInstalled fails unless the version is installed, yum reports success for installs it skipped.
+func (y *YumManager) ensureInstalled(ctx context.Context, packageName, version string) error {
+	pkg, err := y.GetInstalledPackage(ctx, packageName)
+	if err != nil {
+		return err
+	}
+
+	if CompareRPMVersions(pkg.InstalledVersion, version) != 0 {
+		return fmt.Errorf("package %s is at %s after installing %s", packageName, pkg.InstalledVersion, version)
+	}
+
+	return nil
+}
+
+func (y *YumManager) DowngradePackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	y.logger.Info("Attempting to downgrade package", "pkg", packageName, "version", version)
+	cmd := exec.CommandContext(ctx, "sudo", "yum", "--repo", y.repos.repoOf(packageName), "downgrade", "-y", packageName+"-"+version)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		y.logger.Error("Failed to downgrade package", err, "pkg", packageName, "version", version)
+		return fmt.Errorf("failed to downgrade package %s-%s: %w", packageName, version, err)
+	}
+
+	y.logger.Info("Package downgraded successfully", "pkg", packageName, "version", version)
+	return nil
+}
+
+func (y *YumManager) ListVersions(ctx context.Context, packageName string) ([]PackageVersion, error) {
+	available, err := y.availableVersions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed string
+	if pkg, err := y.GetInstalledPackage(ctx, packageName); err == nil {
+		installed = pkg.InstalledVersion
+	}
+
+	return buildVersionList(available, installed, CompareRPMVersions), nil
+}
+
 func (y *YumManager) UpdateRepository(ctx context.Context) error {
 	// sudo yum --repo dvnet list available --refresh"
-	out, err := exec.CommandContext(ctx, "sudo", "yum", "--repo", "dvnet", "list", "available", "--refresh").Output()
+	args := []string{"yum"}
+	for _, repoID := range y.repos.reposInUse() {
+		args = append(args, "--repo", repoID)
+	}
+	out, err := exec.CommandContext(ctx, "sudo", append(args, "list", "available", "--refresh")...).Output() //nolint:gosec
 
 	if err != nil {
 		y.logger.Error("Failed to update package: %v", err)
-		return errors.New("failed to update package list")
+		return fmt.Errorf("failed to update package list: %w", err)
 	}
 
 	y.logger.Info("Package list updated successfully")
@@ -69,6 +164,52 @@ func (y *YumManager) UpdateRepository(ctx context.Context) error {
 	return nil
 }
 
+func (y *YumManager) SimulateUpgrade(ctx context.Context, packageName, version string) (UpgradePlan, error) {
+	args := []string{"yum", "--repo", y.repos.repoOf(packageName)}
+	if version != "" {
+		versions, err := y.availableVersions(ctx, packageName)
+		if err != nil {
+			return UpgradePlan{}, err
+		}
+
+		fullVersion, ok := matchVersion(versions, version)
+		if !ok {
+			return UpgradePlan{}, fmt.Errorf("%w: %s-%s", ErrVersionNotFound, packageName, version)
+		}
+		args = append(args, "install", "--assumeno", packageName+"-"+fullVersion)
+	} else {
+		args = append(args, "update", "--assumeno", packageName)
+	}
+
+	// yum exits with an error when the transaction is declined, the output tells it apart from real failures
+	out, err := exec.CommandContext(ctx, "sudo", args...).CombinedOutput() //nolint:gosec
+	if err != nil && !bytes.Contains(out, []byte("Operation aborted")) && !bytes.Contains(out, []byte("Exiting on user command")) {
+		y.logger.Error("Failed to simulate upgrade", err, "pkg", packageName, "out", string(out))
+		return UpgradePlan{}, fmt.Errorf("failed to simulate upgrade of %s: %w, output: %s", packageName, err, string(out))
+	}
+
+	changes := parseYumTransaction(out)
+	// yum does not print the versions being replaced, the requested package is the one that matters
+	if pkg, err := y.GetInstalledPackage(ctx, packageName); err == nil {
+		for i := range changes {
+			if changes[i].Name == packageName && changes[i].CurrentVersion == "" {
+				changes[i].CurrentVersion = pkg.InstalledVersion
+			}
+		}
+	}
+
+	return UpgradePlan{
+		Package: packageName,
+		Version: version,
+		Changes: changes,
+	}, nil
+}
+
+// SetChannel makes the package follow the release channel, see rpmRepos.setChannel.
+func (y *YumManager) SetChannel(ctx context.Context, packageName string, channel Channel) error {
+	return y.repos.setChannel(ctx, packageName, channel)
+}
+
 func (y *YumManager) SearchPackage(ctx context.Context, packageName string) ([]string, error) {
 	out, err := exec.CommandContext(ctx, "sudo", "yum", "search", packageName).Output()
 	if err != nil {
@@ -90,6 +231,31 @@ func (y *YumManager) SearchPackage(ctx context.Context, packageName string) ([]s
 	return results, nil
 }
 
+// availableVersions returns every version-release of the package offered by the dvnet repository.
+func (y *YumManager) availableVersions(ctx context.Context, packageName string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, "sudo", "yum", "--repo="+y.repos.repoOf(packageName), "--showduplicates", "list", "available", packageName).Output()
+	if err != nil {
+		y.logger.Error("Failed to list package versions", err, "pkg", packageName)
+		return nil, fmt.Errorf("failed to list versions of %s: %w", packageName, err)
+	}
+
+	return y.parseDuplicatesOutput(out, packageName), nil
+}
+
+// parseDuplicatesOutput parses lines like "dv-merchant.x86_64    1.4.2-1    dvnet".
+func (y *YumManager) parseDuplicatesOutput(out []byte, packageName string) []string {
+	var versions []string
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 || strings.Split(parts[0], ".")[0] != packageName {
+			continue
+		}
+		versions = append(versions, parts[1])
+	}
+
+	return versions
+}
+
 func (y *YumManager) parseYumOutput(out []byte, packageName string) (Package, error) {
 	lines := strings.Split(string(out), "\n")
 
@@ -131,20 +297,14 @@ func (y *YumManager) parseYumOutput(out []byte, packageName string) (Package, er
 		return Package{}, errors.New("package not found")
 	}
 
-	needForUpdate := false
-
 	if availableVersion == "" {
 		availableVersion = installedVersion
 	}
 
-	if installedVersion != availableVersion {
-		needForUpdate = true
-	}
-
 	return Package{
 		Name:             packageName,
 		InstalledVersion: installedVersion,
 		AvailableVersion: availableVersion,
-		NeedForUpdate:    needForUpdate,
+		NeedForUpdate:    needForUpdate(installedVersion, availableVersion, CompareRPMVersions),
 	}, nil
 }
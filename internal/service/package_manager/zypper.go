@@ -0,0 +1,274 @@
+package package_manager
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/pkg/logger"
+)
+
+// ZypperManager manages packages on openSUSE and SLES. Queries use the XML output of zypper,
+// its tables are meant for humans and change between releases.
+type ZypperManager struct {
+	logger logger.Logger
+	repos  *rpmRepos
+}
+
+var _ PackageManager = (*ZypperManager)(nil)
+
+func NewZypperManager(log logger.Logger, repoConf config.RepositoryConfig) *ZypperManager {
+	return &ZypperManager{
+		logger: log,
+		repos:  newRPMRepos(log, repoConf, zypperReposPath),
+	}
+}
+
+func (z *ZypperManager) GetInstalledPackage(ctx context.Context, packageName string) (Package, error) {
+	return installedRPMPackage(ctx, z.logger, packageName)
+}
+
+func (z *ZypperManager) CheckForUpdates(ctx context.Context, packageName string) (Package, error) {
+	pkg, err := z.GetInstalledPackage(ctx, packageName)
+	if err != nil {
+		return Package{}, err
+	}
+
+	out, err := z.query(ctx, "list-updates", "--repo", z.repos.repoOf(packageName))
+	if err != nil {
+		z.logger.Error("Failed to check for updates", err, "pkg", packageName)
+		return Package{}, ErrNothingToUpdate
+	}
+
+	for _, u := range out.Updates {
+		if u.Kind == "package" && u.Name == packageName && CompareRPMVersions(u.Edition, pkg.AvailableVersion) > 0 {
+			pkg.AvailableVersion = u.Edition
+		}
+	}
+	pkg.NeedForUpdate = needForUpdate(pkg.InstalledVersion, pkg.AvailableVersion, CompareRPMVersions)
+
+	return pkg, nil
+}
+
+func (z *ZypperManager) UpgradePackage(ctx context.Context, packageName string, output io.Writer) error {
+	z.logger.Info("Attempting to upgrade package", "pkg", packageName)
+	if err := z.run(ctx, output, "update", "--repo", z.repos.repoOf(packageName), packageName); err != nil {
+		z.logger.Error("Failed to upgrade package", err, "pkg", packageName)
+		return fmt.Errorf("failed to upgrade package %s: %w", packageName, err)
+	}
+
+	z.logger.Info("Package upgraded successfully", "pkg", packageName)
+	return nil
+}
+
+func (z *ZypperManager) InstallPackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	fullVersion, err := z.resolveVersion(ctx, packageName, version)
+	if err != nil {
+		return err
+	}
+
+	z.logger.Info("Attempting to install package version", "pkg", packageName, "version", fullVersion)
+	if err = z.run(ctx, output, "install", "--oldpackage", "--repo", z.repos.repoOf(packageName), packageName+"="+fullVersion); err != nil {
+		z.logger.Error("Failed to install package version", err, "pkg", packageName, "version", fullVersion)
+		return fmt.Errorf("failed to install package %s=%s: %w", packageName, fullVersion, err)
+	}
+
+	z.logger.Info("Package version installed successfully", "pkg", packageName, "version", fullVersion)
+	return nil
+}
+
+func (z *ZypperManager) DowngradePackage(ctx context.Context, packageName, version string, output io.Writer) error {
+	z.logger.Info("Attempting to downgrade package", "pkg", packageName, "version", version)
+	if err := z.run(ctx, output, "install", "--oldpackage", "--repo", z.repos.repoOf(packageName), packageName+"="+version); err != nil {
+		z.logger.Error("Failed to downgrade package", err, "pkg", packageName, "version", version)
+		return fmt.Errorf("failed to downgrade package %s=%s: %w", packageName, version, err)
+	}
+
+	z.logger.Info("Package downgraded successfully", "pkg", packageName, "version", version)
+	return nil
+}
+
+func (z *ZypperManager) ListVersions(ctx context.Context, packageName string) ([]PackageVersion, error) {
+	available, err := z.availableVersions(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var installed string
+	if pkg, err := z.GetInstalledPackage(ctx, packageName); err == nil {
+		installed = pkg.InstalledVersion
+	}
+
+	return buildVersionList(available, installed, CompareRPMVersions), nil
+}
+
+func (z *ZypperManager) UpdateRepository(ctx context.Context) error {
+	args := []string{"zypper", "--non-interactive", "--gpg-auto-import-keys", "refresh", "--force"}
+	out, err := exec.CommandContext(ctx, "sudo", append(args, z.repos.reposInUse()...)...).CombinedOutput() //nolint:gosec
+	if err != nil {
+		z.logger.Error("Failed to refresh repository metadata", err, "out", string(out))
+		return fmt.Errorf("failed to refresh repository metadata: %w", err)
+	}
+
+	z.logger.Info("Package list updated successfully")
+	z.logger.Debug("Output: %s", string(out))
+	return nil
+}
+
+func (z *ZypperManager) SetChannel(ctx context.Context, packageName string, channel Channel) error {
+	return z.repos.setChannel(ctx, packageName, channel)
+}
+
+func (z *ZypperManager) SimulateUpgrade(ctx context.Context, packageName, version string) (UpgradePlan, error) {
+	args := []string{"update", "--dry-run", "--repo", z.repos.repoOf(packageName), packageName}
+	if version != "" {
+		fullVersion, err := z.resolveVersion(ctx, packageName, version)
+		if err != nil {
+			return UpgradePlan{}, err
+		}
+		args = []string{"install", "--dry-run", "--oldpackage", "--repo", z.repos.repoOf(packageName), packageName + "=" + fullVersion}
+	}
+
+	out, err := z.simulate(ctx, args...)
+	if err != nil {
+		z.logger.Error("Failed to simulate upgrade", err, "pkg", packageName)
+		return UpgradePlan{}, fmt.Errorf("failed to simulate upgrade of %s: %w", packageName, err)
+	}
+
+	return UpgradePlan{
+		Package: packageName,
+		Version: version,
+		Changes: out.Summary.changes(),
+	}, nil
+}
+
+// availableVersions returns every version of the package offered by the dvnet repository.
+func (z *ZypperManager) availableVersions(ctx context.Context, packageName string) ([]string, error) {
+	out, err := z.query(ctx, "search", "--details", "--match-exact", "--type", "package", "--repo", z.repos.repoOf(packageName), packageName)
+	if err != nil {
+		z.logger.Error("Failed to list package versions", err, "pkg", packageName)
+		return nil, fmt.Errorf("failed to list versions of %s: %w", packageName, err)
+	}
+
+	var versions []string
+	for _, s := range out.Solvables {
+		if s.Name == packageName {
+			versions = append(versions, s.Edition)
+		}
+	}
+
+	return versions, nil
+}
+
+func (z *ZypperManager) resolveVersion(ctx context.Context, packageName, version string) (string, error) {
+	versions, err := z.availableVersions(ctx, packageName)
+	if err != nil {
+		return "", err
+	}
+
+	fullVersion, ok := matchVersion(versions, version)
+	if !ok {
+		return "", fmt.Errorf("%w: %s-%s", ErrVersionNotFound, packageName, version)
+	}
+
+	return fullVersion, nil
+}
+
+// query runs a read-only zypper command with XML output as the updater user, the metadata cached
+// by UpdateRepository is used. Disabled repositories passed with --repo are enabled for the duration
+// of the command.
+func (z *ZypperManager) query(ctx context.Context, args ...string) (zypperStream, error) {
+	args = append([]string{"--xmlout", "--non-interactive", "--no-refresh"}, args...)
+	return z.xml(exec.CommandContext(ctx, "zypper", args...))
+}
+
+// simulate runs a --dry-run transaction, the solver needs root privileges even then.
+func (z *ZypperManager) simulate(ctx context.Context, args ...string) (zypperStream, error) {
+	args = append([]string{"zypper", "--xmlout", "--non-interactive"}, args...)
+	return z.xml(exec.CommandContext(ctx, "sudo", args...)) //nolint:gosec
+}
+
+func (z *ZypperManager) xml(cmd *exec.Cmd) (zypperStream, error) {
+	out, err := cmd.Output()
+	if err != nil {
+		return zypperStream{}, fmt.Errorf("%w, output: %s", err, string(out))
+	}
+
+	return parseZypperXML(out)
+}
+
+func (z *ZypperManager) run(ctx context.Context, output io.Writer, args ...string) error {
+	args = append([]string{"zypper", "--non-interactive", "--gpg-auto-import-keys"}, args...)
+	cmd := exec.CommandContext(ctx, "sudo", args...) //nolint:gosec
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	return cmd.Run()
+}
+
+// zypperStream is the document printed by zypper --xmlout, only the parts of the used commands are mapped.
+type zypperStream struct {
+	Solvables []zypperSolvable `xml:"search-result>solvable-list>solvable"`
+	Updates   []zypperSolvable `xml:"update-status>update-list>update"`
+	Summary   zypperSummary    `xml:"install-summary"`
+}
+
+type zypperSolvable struct {
+	Kind       string `xml:"kind,attr"`
+	Name       string `xml:"name,attr"`
+	Edition    string `xml:"edition,attr"`
+	EditionOld string `xml:"edition-old,attr"`
+	Repository string `xml:"repository,attr"`
+}
+
+// zypperSummary lists the transaction zypper would run:
+//
+//	<install-summary packages-to-change="1">
+//	  <to-upgrade>
+//	    <solvable type="package" name="dv-merchant" edition="1.4.2-1" edition-old="1.4.1-1" repository="dvnet"/>
+//	  </to-upgrade>
+//	</install-summary>
+type zypperSummary struct {
+	Install             []zypperSolvable `xml:"to-install>solvable"`
+	Upgrade             []zypperSolvable `xml:"to-upgrade>solvable"`
+	UpgradeChangeArch   []zypperSolvable `xml:"to-upgrade-change-arch>solvable"`
+	Downgrade           []zypperSolvable `xml:"to-downgrade>solvable"`
+	DowngradeChangeArch []zypperSolvable `xml:"to-downgrade-change-arch>solvable"`
+	Reinstall           []zypperSolvable `xml:"to-reinstall>solvable"`
+	Remove              []zypperSolvable `xml:"to-remove>solvable"`
+}
+
+func (s zypperSummary) changes() []PlannedChange {
+	changes := make([]PlannedChange, 0)
+	add := func(action Action, solvables ...[]zypperSolvable) {
+		for _, list := range solvables {
+			for _, p := range list {
+				change := PlannedChange{Name: p.Name, Action: action, CurrentVersion: p.EditionOld, Version: p.Edition, Repository: p.Repository}
+				if action == ActionRemove {
+					change.CurrentVersion, change.Version = p.Edition, ""
+				}
+				changes = append(changes, change)
+			}
+		}
+	}
+
+	add(ActionInstall, s.Install)
+	add(ActionUpgrade, s.Upgrade, s.UpgradeChangeArch)
+	add(ActionDowngrade, s.Downgrade, s.DowngradeChangeArch)
+	add(ActionReinstall, s.Reinstall)
+	add(ActionRemove, s.Remove)
+
+	return changes
+}
+
+func parseZypperXML(out []byte) (zypperStream, error) {
+	var stream zypperStream
+	if err := xml.Unmarshal(out, &stream); err != nil {
+		return zypperStream{}, fmt.Errorf("parse zypper output: %w", err)
+	}
+
+	return stream, nil
+}
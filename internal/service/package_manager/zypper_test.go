@@ -0,0 +1,125 @@
+package package_manager
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseZypperXML(t *testing.T) {
+	tests := []struct {
+		name          string
+		out           string
+		wantSolvables []zypperSolvable
+		wantUpdates   []zypperSolvable
+		wantChanges   []PlannedChange
+	}{
+		{
+			name: "search",
+			out: `<?xml version='1.0'?>
+<stream>
+<message type="info">Loading repository data...</message>
+<message type="info">Reading installed packages...</message>
+<search-result version="0.0">
+<solvable-list>
+<solvable status="installed" name="dv-merchant" kind="package" edition="1.4.1-1" arch="x86_64" repository="(System Packages)"/>
+<solvable status="other-version" name="dv-merchant" kind="package" edition="1.4.2-1" arch="x86_64" repository="dvnet"/>
+<solvable status="installed" name="dv-merchant" kind="package" edition="1.4.1-1" arch="x86_64" repository="dvnet"/>
+</solvable-list>
+</search-result>
+</stream>
+`,
+			wantSolvables: []zypperSolvable{
+				{Kind: "package", Name: "dv-merchant", Edition: "1.4.1-1", Repository: "(System Packages)"},
+				{Kind: "package", Name: "dv-merchant", Edition: "1.4.2-1", Repository: "dvnet"},
+				{Kind: "package", Name: "dv-merchant", Edition: "1.4.1-1", Repository: "dvnet"},
+			},
+			wantChanges: []PlannedChange{},
+		},
+		{
+			name: "list updates",
+			out: `<?xml version='1.0'?>
+<stream>
+<message type="info">Loading repository data...</message>
+<message type="info">Reading installed packages...</message>
+<update-status version="0.6">
+<update-list>
+<update kind="package" name="dv-merchant" edition="1.4.2-1" arch="x86_64" edition-old="1.4.1-1" ><summary>DV merchant</summary><description>DV merchant backend</description><license></license><source url="https://repo.example.com/rpm/stable" alias="dvnet"/></update>
+<update kind="patch" name="openSUSE-SLE-15.6-2026-812" edition="1" arch="noarch" status="needed" category="security" severity="important" pkgmanager="false" restart="false" interactive="false"><summary>Security update for openssl-3</summary><description></description><license></license><source url="http://download.opensuse.org/update/leap/15.6/sle" alias="repo-sle-update"/></update>
+</update-list>
+</update-status>
+</stream>
+`,
+			wantUpdates: []zypperSolvable{
+				{Kind: "package", Name: "dv-merchant", Edition: "1.4.2-1", EditionOld: "1.4.1-1"},
+				{Kind: "patch", Name: "openSUSE-SLE-15.6-2026-812", Edition: "1"},
+			},
+			wantChanges: []PlannedChange{},
+		},
+		{
+			name: "dry run",
+			out: `<?xml version='1.0'?>
+<stream>
+<message type="info">Loading repository data...</message>
+<message type="info">Reading installed packages...</message>
+<message type="info">Resolving package dependencies...</message>
+<install-summary download-size="11534336" space-usage-diff="1258291" packages-to-change="3">
+<to-upgrade>
+<solvable type="package" name="dv-merchant" edition="1.4.2-1" arch="x86_64" edition-old="1.4.1-1" arch-old="x86_64" summary="DV merchant" repository="dvnet" vendor=""/>
+</to-upgrade>
+<to-install>
+<solvable type="package" name="dv-merchant-migrations" edition="1.4.2-1" arch="noarch" summary="DV merchant migrations" repository="dvnet" vendor=""/>
+</to-install>
+<to-remove>
+<solvable type="package" name="dv-legacy" edition="0.1.0-1" arch="x86_64" summary="" repository="@System" vendor=""/>
+</to-remove>
+</install-summary>
+<message type="info">Continue? [y/n/v/...? shows all options] (y): y</message>
+<message type="info">Dry run: nothing was changed.</message>
+</stream>
+`,
+			wantChanges: []PlannedChange{
+				{Name: "dv-merchant-migrations", Action: ActionInstall, Version: "1.4.2-1", Repository: "dvnet"},
+				{Name: "dv-merchant", Action: ActionUpgrade, CurrentVersion: "1.4.1-1", Version: "1.4.2-1", Repository: "dvnet"},
+				{Name: "dv-legacy", Action: ActionRemove, CurrentVersion: "0.1.0-1", Repository: "@System"},
+			},
+		},
+		{
+			name: "dry run downgrade",
+			out: `<?xml version='1.0'?>
+<stream>
+<install-summary download-size="10276045" space-usage-diff="-20480" packages-to-change="1">
+<to-downgrade>
+<solvable type="package" name="dv-merchant" edition="1.4.1-1" arch="x86_64" edition-old="1.4.2-1" arch-old="x86_64" summary="DV merchant" repository="dvnet" vendor=""/>
+</to-downgrade>
+</install-summary>
+</stream>
+`,
+			wantChanges: []PlannedChange{
+				{Name: "dv-merchant", Action: ActionDowngrade, CurrentVersion: "1.4.2-1", Version: "1.4.1-1", Repository: "dvnet"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := parseZypperXML([]byte(tt.out))
+			if err != nil {
+				t.Fatalf("parseZypperXML: %v", err)
+			}
+
+			if !slices.Equal(stream.Solvables, tt.wantSolvables) {
+				t.Errorf("solvables mismatch\n got: %+v\nwant: %+v", stream.Solvables, tt.wantSolvables)
+			}
+			if !slices.Equal(stream.Updates, tt.wantUpdates) {
+				t.Errorf("updates mismatch\n got: %+v\nwant: %+v", stream.Updates, tt.wantUpdates)
+			}
+			assertChanges(t, stream.Summary.changes(), tt.wantChanges)
+		})
+	}
+}
+
+func TestParseZypperXMLInvalid(t *testing.T) {
+	if _, err := parseZypperXML([]byte("Repository 'dvnet' is invalid.\n")); err == nil {
+		t.Error("expected an error for output which is not XML")
+	}
+}
@@ -0,0 +1,98 @@
+package rollback
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/pkg/jsonfile"
+)
+
+const (
+	historyFile  = "rollback.json"
+	historyLimit = 10
+)
+
+var ErrNoPreviousVersion = errors.New("no previous version recorded")
+
+type Record struct {
+	Version    string    `json:"version"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Service keeps per-package stacks of previously installed versions persisted in the data dir.
+type Service struct {
+	path string
+
+	mu      sync.Mutex
+	history map[string][]Record
+}
+
+func NewService(dataDir string) (*Service, error) {
+	s := &Service{
+		path:    filepath.Join(dataDir, historyFile),
+		history: make(map[string][]Record),
+	}
+
+	if err := jsonfile.Load(s.path, &s.history); err != nil {
+		return nil, fmt.Errorf("load rollback history: %w", err)
+	}
+
+	return s, nil
+}
+
+// Push remembers the version installed before an upgrade.
+func (s *Service) Push(packageName, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := append(s.history[packageName], Record{
+		Version:    version,
+		RecordedAt: time.Now(),
+	})
+	if len(records) > historyLimit {
+		records = records[len(records)-historyLimit:]
+	}
+	s.history[packageName] = records
+
+	return s.save()
+}
+
+// Last returns the most recently remembered version of the package.
+func (s *Service) Last(packageName string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.history[packageName]
+	if len(records) == 0 {
+		return Record{}, fmt.Errorf("%w for %s", ErrNoPreviousVersion, packageName)
+	}
+
+	return records[len(records)-1], nil
+}
+
+// Pop forgets the most recently remembered version of the package.
+func (s *Service) Pop(packageName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.history[packageName]
+	if len(records) == 0 {
+		return nil
+	}
+
+	if len(records) == 1 {
+		delete(s.history, packageName)
+	} else {
+		s.history[packageName] = records[:len(records)-1]
+	}
+
+	return s.save()
+}
+
+// save persists the history. Must be called with mu held.
+func (s *Service) save() error {
+	return jsonfile.Save(s.path, s.history)
+}
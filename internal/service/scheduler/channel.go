@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Channel string
+
+const (
+	ChannelPatch Channel = "patch"
+	ChannelMinor Channel = "minor"
+	ChannelAny   Channel = "any"
+)
+
+// allows reports whether moving from installed to candidate stays within the channel.
+func (c Channel) allows(installed, candidate string) bool {
+	if c == ChannelAny {
+		return true
+	}
+
+	from, okFrom := versionCore(installed)
+	to, okTo := versionCore(candidate)
+	if !okFrom || !okTo {
+		return false
+	}
+
+	switch c {
+	case ChannelPatch:
+		return from[0] == to[0] && from[1] == to[1]
+	case ChannelMinor:
+		return from[0] == to[0]
+	default:
+		return false
+	}
+}
+
+// versionCore extracts major, minor and patch from package versions like "1:1.4.2-1".
+func versionCore(version string) ([3]int, bool) {
+	var core [3]int
+
+	if i := strings.Index(version, ":"); i >= 0 {
+		version = version[i+1:]
+	}
+	if i := strings.IndexAny(version, "-~+"); i >= 0 {
+		version = version[:i]
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return core, false
+	}
+
+	for i := 0; i < len(core) && i < len(parts); i++ {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return core, false
+		}
+		core[i] = n
+	}
+
+	return core, true
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/pkg/jsonfile"
+)
+
+const releasesFile = "releases.json"
+
+// releases remembers when every package version was first seen in the repository.
+// Package managers do not report publication dates, so the first sighting stands in for the release time.
+type releases struct {
+	path string
+
+	mu        sync.Mutex
+	firstSeen map[string]map[string]time.Time
+}
+
+func newReleases(dataDir string) (*releases, error) {
+	r := &releases{
+		path:      filepath.Join(dataDir, releasesFile),
+		firstSeen: make(map[string]map[string]time.Time),
+	}
+
+	if err := jsonfile.Load(r.path, &r.firstSeen); err != nil {
+		return nil, fmt.Errorf("load releases: %w", err)
+	}
+
+	return r, nil
+}
+
+// observe records the versions which were not seen before.
+func (r *releases) observe(packageName string, versions []string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen, ok := r.firstSeen[packageName]
+	if !ok {
+		seen = make(map[string]time.Time, len(versions))
+		r.firstSeen[packageName] = seen
+	}
+
+	changed := false
+	for _, v := range versions {
+		if _, ok := seen[v]; !ok {
+			seen[v] = now
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.save()
+}
+
+func (r *releases) get(packageName, version string) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.firstSeen[packageName][version]
+	return t, ok
+}
+
+// save persists the releases. Must be called with mu held.
+func (r *releases) save() error {
+	return jsonfile.Save(r.path, r.firstSeen)
+}
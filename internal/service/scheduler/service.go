@@ -0,0 +1,297 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/service/audit"
+	"github.com/dv-net/dv-updater/internal/service/catalog"
+	"github.com/dv-net/dv-updater/internal/service/channel"
+	"github.com/dv-net/dv-updater/internal/service/package_manager"
+	"github.com/dv-net/dv-updater/internal/service/updater"
+	"github.com/dv-net/dv-updater/pkg/logger"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	checkInterval         = 30 * time.Second
+	defaultWindowDuration = time.Hour
+)
+
+// Plan describes an auto-update policy. NextRun is the start of the next window, OpenUntil is set
+// while a window is open.
+type Plan struct {
+	Package   string     `json:"package"`
+	Window    string     `json:"window"`
+	Duration  string     `json:"duration"`
+	Channel   Channel    `json:"channel"`
+	Delay     string     `json:"delay"`
+	NextRun   time.Time  `json:"next_run"`
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type policy struct {
+	plan     Plan
+	delay    time.Duration
+	schedule cron.Schedule
+	duration time.Duration
+	// failed is the start of the window an upgrade failed in, it is retried in the next window
+	failed time.Time
+}
+
+// window returns the bounds of the maintenance window open at now. Windows are looked up from now,
+// so a window which started while the service was down is still used until it closes.
+func (p *policy) window(now time.Time) (start, end time.Time, open bool) {
+	start = p.schedule.Next(now.Add(-p.duration))
+	end = start.Add(p.duration)
+	return start, end, !start.After(now)
+}
+
+// Service upgrades managed packages inside their maintenance windows according to auto-update policies.
+type Service struct {
+	logger         logger.Logger
+	channels       *channel.Service
+	packageManager package_manager.PackageManager
+	updater        *updater.Service
+	releases       *releases
+
+	mu       sync.RWMutex
+	policies []*policy
+}
+
+func NewService(
+	l logger.Logger,
+	conf config.AutoUpdateConfig,
+	dataDir string,
+	catalogService *catalog.Service,
+	channelService *channel.Service,
+	pm package_manager.PackageManager,
+	updaterService *updater.Service,
+) (*Service, error) {
+	rel, err := newReleases(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{
+		logger:         l,
+		channels:       channelService,
+		packageManager: pm,
+		updater:        updaterService,
+		releases:       rel,
+	}
+
+	for _, pc := range conf.Policies {
+		pkg, ok := catalogService.Get(pc.Package)
+		if !ok {
+			return nil, fmt.Errorf("auto-update policy: %w: %s", catalog.ErrUnknownPackage, pc.Package)
+		}
+		if !pkg.AutoUpdate {
+			l.Warn("auto-update is not allowed for package, policy ignored", "pkg", pc.Package)
+			continue
+		}
+
+		schedule, err := cron.ParseStandard(pc.Window)
+		if err != nil {
+			return nil, fmt.Errorf("auto-update policy %s: invalid window %q: %w", pc.Package, pc.Window, err)
+		}
+
+		duration := pc.Duration
+		if duration == 0 {
+			duration = defaultWindowDuration
+		}
+
+		channel := Channel(pc.Channel)
+		if channel == "" {
+			channel = ChannelPatch
+		}
+
+		s.policies = append(s.policies, &policy{
+			plan: Plan{
+				Package:  pc.Package,
+				Window:   pc.Window,
+				Duration: duration.String(),
+				Channel:  channel,
+				Delay:    pc.Delay.String(),
+			},
+			delay:    pc.Delay,
+			schedule: schedule,
+			duration: duration,
+		})
+	}
+
+	return s, nil
+}
+
+// Plans returns the scheduled auto-updates.
+func (s *Service) Plans() []Plan {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	plans := make([]Plan, 0, len(s.policies))
+	for _, p := range s.policies {
+		plan := p.plan
+		plan.NextRun = p.schedule.Next(now)
+		if _, end, open := p.window(now); open {
+			plan.OpenUntil = &end
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans
+}
+
+// Run upgrades packages inside their maintenance windows until ctx is done. Every check inside a window
+// looks for an upgrade, so versions whose delay expires while the window is open are installed too.
+func (s *Service) Run(ctx context.Context) {
+	for _, plan := range s.Plans() {
+		s.logger.Info("auto-update planned", "pkg", plan.Package, "next_run", plan.NextRun)
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, p := range s.duePolicies(now) {
+				target, err := s.upgrade(ctx, p)
+				s.finish(p, now, target, err)
+			}
+		}
+	}
+}
+
+// Observe records the versions currently offered for scheduled packages, it is called after every repository refresh.
+func (s *Service) Observe(ctx context.Context) {
+	now := time.Now()
+	for _, plan := range s.Plans() {
+		versions, err := s.packageManager.ListVersions(ctx, plan.Package)
+		if err != nil {
+			s.logger.Error("failed to observe package versions", err, "pkg", plan.Package)
+			continue
+		}
+
+		names := make([]string, 0, len(versions))
+		for _, v := range versions {
+			names = append(names, v.Version)
+		}
+
+		if err = s.releases.observe(plan.Package, names, now); err != nil {
+			s.logger.Error("failed to record package versions", err, "pkg", plan.Package)
+		}
+	}
+}
+
+// duePolicies returns the policies whose window is open at now and has not seen a failed upgrade.
+func (s *Service) duePolicies(now time.Time) []*policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var due []*policy
+	for _, p := range s.policies {
+		start, _, open := p.window(now)
+		if open && !p.failed.Equal(start) {
+			due = append(due, p)
+		}
+	}
+
+	return due
+}
+
+// finish records the outcome of an upgrade, checks which found nothing to install leave no trace.
+func (s *Service) finish(p *policy, now time.Time, target string, err error) {
+	if target == "" && err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p.plan.LastRun = &now
+	p.plan.LastError = ""
+	if err != nil {
+		p.plan.LastError = err.Error()
+		p.failed, _, _ = p.window(now)
+		s.logger.Error("scheduled auto-update failed", err, "pkg", p.plan.Package, "next_run", p.schedule.Next(now))
+	}
+}
+
+// upgrade installs the version picked for the policy and returns it, empty when there is nothing to install.
+func (s *Service) upgrade(ctx context.Context, p *policy) (string, error) {
+	s.mu.RLock()
+	plan := p.plan
+	s.mu.RUnlock()
+
+	target, err := s.pickVersion(ctx, plan, p.delay)
+	if err != nil || target == "" {
+		return "", err
+	}
+
+	s.logger.Info("scheduled auto-update started", "pkg", plan.Package, "version", target)
+	res, err := s.updater.Install(audit.WithRequester(ctx, "scheduler"), plan.Package, target, io.Discard)
+	if err != nil {
+		return target, err
+	}
+
+	s.logger.Info("scheduled auto-update finished", "pkg", plan.Package, "version", res.Package.InstalledVersion)
+	return target, nil
+}
+
+// pickVersion returns the newest version newer than the installed one which fits the channel and delay.
+func (s *Service) pickVersion(ctx context.Context, plan Plan, delay time.Duration) (string, error) {
+	versions, err := s.packageManager.ListVersions(ctx, plan.Package)
+	if err != nil {
+		return "", err
+	}
+
+	var installed string
+	for _, v := range versions {
+		if v.Installed {
+			installed = v.Version
+			break
+		}
+	}
+	if installed == "" {
+		return "", fmt.Errorf("package %s is not installed", plan.Package)
+	}
+
+	stable := s.channels.Get(plan.Package) == package_manager.ChannelStable
+	now := time.Now()
+	// versions are sorted from the newest, everything before the installed one is an upgrade
+	for _, v := range versions {
+		if v.Installed {
+			break
+		}
+
+		if !plan.Channel.allows(installed, v.Version) {
+			continue
+		}
+
+		if stable && package_manager.IsPreRelease(v.Version) && !package_manager.IsPreRelease(installed) {
+			continue
+		}
+
+		if delay > 0 {
+			firstSeen, ok := s.releases.get(plan.Package, v.Version)
+			if !ok || now.Sub(firstSeen) < delay {
+				s.logger.Debug("version is too fresh for auto-update", "pkg", plan.Package, "version", v.Version)
+				continue
+			}
+		}
+
+		return v.Version, nil
+	}
+
+	return "", nil
+}
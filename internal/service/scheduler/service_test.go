@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any)        {}
+func (nopLogger) Info(string, ...any)         {}
+func (nopLogger) Warn(string, ...any)         {}
+func (nopLogger) Error(string, error, ...any) {}
+func (nopLogger) Fatal(string, error, ...any) {}
+
+func newTestService(t *testing.T, window string, duration time.Duration) (*Service, *policy) {
+	t.Helper()
+
+	schedule, err := cron.ParseStandard(window)
+	if err != nil {
+		t.Fatalf("parse window %q: %v", window, err)
+	}
+
+	p := &policy{
+		plan:     Plan{Package: "dv-merchant", Window: window},
+		schedule: schedule,
+		duration: duration,
+	}
+
+	return &Service{logger: nopLogger{}, policies: []*policy{p}}, p
+}
+
+func at(hour, minute, second int) time.Time {
+	return time.Date(2026, time.March, 10, hour, minute, second, 0, time.UTC)
+}
+
+func TestPolicyWindow(t *testing.T) {
+	_, p := newTestService(t, "0 3 * * *", 2*time.Hour)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantOpen  bool
+		wantStart time.Time
+	}{
+		{name: "before the window", now: at(2, 59, 0), wantOpen: false, wantStart: at(3, 0, 0)},
+		{name: "window start", now: at(3, 0, 0), wantOpen: true, wantStart: at(3, 0, 0)},
+		{name: "inside the window", now: at(4, 30, 0), wantOpen: true, wantStart: at(3, 0, 0)},
+		{name: "window end", now: at(5, 0, 0), wantOpen: false, wantStart: at(3, 0, 0).AddDate(0, 0, 1)},
+		{name: "after the window", now: at(12, 0, 0), wantOpen: false, wantStart: at(3, 0, 0).AddDate(0, 0, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, open := p.window(tt.now)
+			if open != tt.wantOpen {
+				t.Errorf("open = %v, want %v", open, tt.wantOpen)
+			}
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("start = %s, want %s", start, tt.wantStart)
+			}
+			if want := tt.wantStart.Add(2 * time.Hour); !end.Equal(want) {
+				t.Errorf("end = %s, want %s", end, want)
+			}
+		})
+	}
+}
+
+// A version whose delay expires while the window is open is installed by a later check of the same window.
+func TestDuePoliciesEveryCheckInsideWindow(t *testing.T) {
+	s, p := newTestService(t, "0 3 * * *", 2*time.Hour)
+
+	checks := []struct {
+		now     time.Time
+		target  string
+		wantDue bool
+	}{
+		{now: at(2, 59, 30), wantDue: false},
+		{now: at(3, 0, 0), target: "", wantDue: true},
+		{now: at(3, 30, 0), target: "", wantDue: true},
+		{now: at(4, 30, 0), target: "1.4.3", wantDue: true},
+		{now: at(4, 59, 30), target: "", wantDue: true},
+		{now: at(5, 0, 0), wantDue: false},
+	}
+
+	for _, c := range checks {
+		due := s.duePolicies(c.now)
+		if got := len(due) == 1; got != c.wantDue {
+			t.Fatalf("%s: due = %v, want %v", c.now.Format(time.TimeOnly), got, c.wantDue)
+		}
+		if c.wantDue {
+			s.finish(p, c.now, c.target, nil)
+		}
+	}
+
+	if p.plan.LastRun == nil || !p.plan.LastRun.Equal(at(4, 30, 0)) {
+		t.Errorf("last run = %v, want the install at 04:30", p.plan.LastRun)
+	}
+}
+
+// A window which started while the service was down is used by the first check after the start.
+func TestDuePoliciesWindowStartedWhileDown(t *testing.T) {
+	tests := []struct {
+		name    string
+		started time.Time
+		wantDue bool
+	}{
+		{name: "started inside the window", started: at(3, 40, 0), wantDue: true},
+		{name: "started right before the window closes", started: at(4, 59, 30), wantDue: true},
+		{name: "started after the window", started: at(5, 10, 0), wantDue: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t, "0 3 * * *", 2*time.Hour)
+
+			if got := len(s.duePolicies(tt.started)) == 1; got != tt.wantDue {
+				t.Errorf("due = %v, want %v", got, tt.wantDue)
+			}
+		})
+	}
+}
+
+func TestDuePoliciesFailedUpgradeWaitsForNextWindow(t *testing.T) {
+	s, p := newTestService(t, "0 3 * * *", 2*time.Hour)
+
+	s.finish(p, at(3, 10, 0), "1.4.3", errors.New("dpkg was interrupted"))
+
+	if p.plan.LastError == "" {
+		t.Fatal("last error is not recorded")
+	}
+	if due := s.duePolicies(at(3, 20, 0)); len(due) != 0 {
+		t.Errorf("policy is due again in the window its upgrade failed in")
+	}
+	if due := s.duePolicies(at(3, 0, 0).AddDate(0, 0, 1)); len(due) != 1 {
+		t.Errorf("policy is not due in the next window")
+	}
+}
@@ -2,53 +2,106 @@ package service
 
 import (
 	"errors"
-	"fmt"
 
+	"github.com/dv-net/dv-updater/internal/config"
 	"github.com/dv-net/dv-updater/internal/distro"
+	"github.com/dv-net/dv-updater/internal/service/audit"
+	"github.com/dv-net/dv-updater/internal/service/catalog"
+	"github.com/dv-net/dv-updater/internal/service/channel"
+	"github.com/dv-net/dv-updater/internal/service/coordinator"
+	"github.com/dv-net/dv-updater/internal/service/health"
+	"github.com/dv-net/dv-updater/internal/service/job"
 	"github.com/dv-net/dv-updater/internal/service/package_manager"
+	"github.com/dv-net/dv-updater/internal/service/rollback"
+	"github.com/dv-net/dv-updater/internal/service/scheduler"
+	"github.com/dv-net/dv-updater/internal/service/status"
 	systeminfo "github.com/dv-net/dv-updater/internal/service/system_info"
+	"github.com/dv-net/dv-updater/internal/service/updater"
+	"github.com/dv-net/dv-updater/internal/service/webhook"
 	"github.com/dv-net/dv-updater/pkg/logger"
 )
 
 const (
-	DVUpdaterServiceName    string = "dv-updater"
-	DVMerchantServiceName   string = "dv-merchant"
-	DVProcessingServiceName string = "dv-processing"
+	DVUpdaterServiceName string = "dv-updater"
 )
 
-func ValidateServiceName(serviceName string) error {
-	switch serviceName {
-	case DVMerchantServiceName, DVUpdaterServiceName, DVProcessingServiceName:
-		return nil
-	default:
-		return fmt.Errorf("invalid service name")
-	}
-}
-
 type Services struct {
-	PackageManager    package_manager.PackageManager
-	SystemInfoService *systeminfo.Service
+	PackageManager     package_manager.PackageManager
+	CatalogService     *catalog.Service
+	AuditService       *audit.Service
+	CoordinatorService *coordinator.Service
+	ChannelService     *channel.Service
+	SystemInfoService  *systeminfo.Service
+	UpdaterService     *updater.Service
+	JobService         *job.Service
+	SchedulerService   *scheduler.Service
+	StatusService      *status.Service
+	WebhookService     *webhook.Service
 }
 
-func NewServices(l logger.Logger, dist distro.LinuxDistro, currentAppVersion, currentAppCommitHash string) (*Services, error) {
+func NewServices(conf *config.Config, l logger.Logger, dist distro.LinuxDistro, currentAppVersion, currentAppCommitHash string) (*Services, error) {
 	var (
 		pm  package_manager.PackageManager
 		err error
 	)
-	switch dist.ID {
-	case "debian", "ubuntu":
-		pm, err = package_manager.NewAptManager(l)
+	switch dist.Family() {
+	case distro.FamilyDebian:
+		pm, err = package_manager.NewAptManager(l, conf.Repository)
 		if err != nil {
 			return nil, err
 		}
-	case "centos", "rhel":
-		pm = package_manager.NewYumManager(l)
+	case distro.FamilyRHEL:
+		// dnf replaced yum in RHEL 8 and Fedora 22, CentOS 7 and Amazon Linux 2 hosts only have yum
+		if package_manager.DnfAvailable() {
+			pm = package_manager.NewDnfManager(l, conf.Repository)
+		} else {
+			pm = package_manager.NewYumManager(l, conf.Repository)
+		}
+	case distro.FamilySUSE:
+		pm = package_manager.NewZypperManager(l, conf.Repository)
+	case distro.FamilyAlpine:
+		pm = package_manager.NewApkManager(l, conf.Repository)
 	default:
-		l.Fatal("Unsupported distribution", errors.New("unsupported distro"))
+		l.Fatal("Unsupported distribution", errors.New("unsupported distro"), "name", dist.Name, "id", dist.ID)
+	}
+
+	rollbackService, err := rollback.NewService(conf.App.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	auditService, err := audit.NewService(conf.Audit, conf.App.DataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogService := catalog.NewService(DVUpdaterServiceName, conf.Packages)
+	coordinatorService := coordinator.NewService(l)
+	channelService, err := channel.NewService(l, conf.App.DataDir, DVUpdaterServiceName, catalogService, coordinatorService, pm)
+	if err != nil {
+		return nil, err
+	}
+
+	statusService := status.NewService(conf.HTTP.FetchInterval, pm, catalogService, channelService)
+	webhookService := webhook.NewService(l, conf.Webhooks)
+	updaterService := updater.NewService(l, pm, rollbackService, catalogService, health.NewService(l), auditService, coordinatorService, statusService, webhookService)
+
+	schedulerService, err := scheduler.NewService(l, conf.AutoUpdate, conf.App.DataDir, catalogService, channelService, pm, updaterService)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Services{
-		PackageManager:    pm,
-		SystemInfoService: systeminfo.NewService(currentAppVersion, currentAppCommitHash),
+		PackageManager:     pm,
+		CatalogService:     catalogService,
+		AuditService:       auditService,
+		CoordinatorService: coordinatorService,
+		ChannelService:     channelService,
+		SystemInfoService:  systeminfo.NewService(currentAppVersion, currentAppCommitHash),
+		UpdaterService:     updaterService,
+		JobService:         job.NewService(l, conf.Jobs, coordinatorService),
+		SchedulerService:   schedulerService,
+		StatusService:      statusService,
+		WebhookService:     webhookService,
 	}, nil
 }
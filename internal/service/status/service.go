@@ -0,0 +1,183 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/service/catalog"
+	"github.com/dv-net/dv-updater/internal/service/channel"
+	"github.com/dv-net/dv-updater/internal/service/package_manager"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type PackageStatus struct {
+	package_manager.Package
+	DisplayName string                  `json:"display_name,omitempty"`
+	Channel     package_manager.Channel `json:"channel"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+// Snapshot is the status of the updater and every managed package at RefreshedAt.
+type Snapshot struct {
+	Packages    []PackageStatus `json:"packages"`
+	RefreshedAt time.Time       `json:"refreshed_at"`
+}
+
+type cached struct {
+	pkg       package_manager.Package
+	err       error
+	fetchedAt time.Time
+}
+
+// Service caches package status for ttl, so API requests do not spawn package manager commands.
+// Concurrent requests of the same status share a single query. The cache is invalidated after
+// repository refreshes, upgrades and channel switches.
+type Service struct {
+	packageManager package_manager.PackageManager
+	catalog        *catalog.Service
+	channels       *channel.Service
+	ttl            time.Duration
+	group          singleflight.Group
+
+	mu       sync.RWMutex
+	snapshot Snapshot
+	packages map[string]cached
+	// generation is bumped on invalidation, queries started before do not join or fill the new cache
+	generation uint64
+}
+
+func NewService(ttl time.Duration, pm package_manager.PackageManager, catalogService *catalog.Service, channelService *channel.Service) *Service {
+	return &Service{
+		packageManager: pm,
+		catalog:        catalogService,
+		channels:       channelService,
+		ttl:            ttl,
+		packages:       make(map[string]cached),
+	}
+}
+
+// Package returns the update check result of the package, see PackageManager.CheckForUpdates.
+func (s *Service) Package(ctx context.Context, name string) (package_manager.Package, error) {
+	s.mu.RLock()
+	c, ok := s.packages[name]
+	generation := s.generation
+	s.mu.RUnlock()
+	if ok && s.fresh(c.fetchedAt) {
+		return c.pkg, c.err
+	}
+
+	// the query is shared, a cancelled request must not fail the others
+	sharedCtx := context.WithoutCancel(ctx)
+	v, err, _ := s.group.Do(fmt.Sprintf("package:%s:%d", name, generation), func() (any, error) {
+		pkg, err := s.packageManager.CheckForUpdates(sharedCtx, name)
+		// apt reports packages without updates as an error, it is an answer worth caching
+		if err == nil || errors.Is(err, package_manager.ErrNothingToUpdate) {
+			s.mu.Lock()
+			if s.generation == generation {
+				s.packages[name] = cached{pkg: pkg, err: err, fetchedAt: time.Now()}
+			}
+			s.mu.Unlock()
+		}
+		return pkg, err
+	})
+
+	return v.(package_manager.Package), err
+}
+
+// Snapshot returns the status of all packages, it is taken again once older than ttl.
+func (s *Service) Snapshot(ctx context.Context) Snapshot {
+	s.mu.RLock()
+	snapshot := s.snapshot
+	s.mu.RUnlock()
+
+	if s.fresh(snapshot.RefreshedAt) {
+		return snapshot
+	}
+
+	return s.take(ctx)
+}
+
+// Refresh drops the cache and takes a new snapshot, it is called after repository refreshes.
+func (s *Service) Refresh(ctx context.Context) Snapshot {
+	s.Invalidate()
+	return s.take(ctx)
+}
+
+// Invalidate drops the cached status of the packages, of all packages when none are given.
+func (s *Service) Invalidate(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(names) == 0 {
+		clear(s.packages)
+	}
+	for _, name := range names {
+		delete(s.packages, name)
+	}
+	s.snapshot.RefreshedAt = time.Time{}
+	s.generation++
+}
+
+func (s *Service) take(ctx context.Context) Snapshot {
+	s.mu.RLock()
+	generation := s.generation
+	s.mu.RUnlock()
+
+	sharedCtx := context.WithoutCancel(ctx)
+	v, _, _ := s.group.Do(fmt.Sprintf("snapshot:%d", generation), func() (any, error) {
+		names := []string{s.catalog.Self()}
+		for _, pkg := range s.catalog.Packages() {
+			names = append(names, pkg.Name)
+		}
+
+		snapshot := Snapshot{Packages: make([]PackageStatus, 0, len(names))}
+		for _, name := range names {
+			snapshot.Packages = append(snapshot.Packages, s.status(sharedCtx, name))
+		}
+		snapshot.RefreshedAt = time.Now()
+
+		s.mu.Lock()
+		if s.generation == generation {
+			s.snapshot = snapshot
+		}
+		s.mu.Unlock()
+
+		return snapshot, nil
+	})
+
+	return v.(Snapshot)
+}
+
+func (s *Service) status(ctx context.Context, name string) PackageStatus {
+	st := PackageStatus{
+		Package: package_manager.Package{Name: name},
+		Channel: s.channels.Get(name),
+	}
+	if pkg, ok := s.catalog.Get(name); ok {
+		st.DisplayName = pkg.DisplayName
+	}
+
+	installed, err := s.packageManager.GetInstalledPackage(ctx, name)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+
+	// apt lists only upgradable packages, an error means there is nothing newer
+	updates, err := s.Package(ctx, name)
+	if err != nil || updates.AvailableVersion == "" {
+		updates = installed
+		updates.AvailableVersion = installed.InstalledVersion
+	}
+
+	st.Package = updates
+	return st
+}
+
+func (s *Service) fresh(at time.Time) bool {
+	return !at.IsZero() && time.Since(at) < s.ttl
+}
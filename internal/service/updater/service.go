@@ -0,0 +1,334 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/metrics"
+	"github.com/dv-net/dv-updater/internal/service/audit"
+	"github.com/dv-net/dv-updater/internal/service/catalog"
+	"github.com/dv-net/dv-updater/internal/service/coordinator"
+	"github.com/dv-net/dv-updater/internal/service/health"
+	"github.com/dv-net/dv-updater/internal/service/package_manager"
+	"github.com/dv-net/dv-updater/internal/service/rollback"
+	"github.com/dv-net/dv-updater/internal/service/status"
+	"github.com/dv-net/dv-updater/internal/service/webhook"
+	"github.com/dv-net/dv-updater/pkg/logger"
+)
+
+// restartNotifyTimeout bounds how long a self-update waits for the restart webhook.
+const restartNotifyTimeout = 30 * time.Second
+
+var ErrUnhealthy = errors.New("package is unhealthy after upgrade")
+
+// Result describes the package state after an operation.
+type Result struct {
+	Package    package_manager.Package `json:"package"`
+	Health     *health.Result          `json:"health,omitempty"`
+	RolledBack bool                    `json:"rolled_back,omitempty"`
+}
+
+type Service struct {
+	logger          logger.Logger
+	packageManager  package_manager.PackageManager
+	rollbackService *rollback.Service
+	catalog         *catalog.Service
+	health          *health.Service
+	audit           *audit.Service
+	coordinator     *coordinator.Service
+	status          *status.Service
+	webhooks        *webhook.Service
+}
+
+func NewService(
+	l logger.Logger,
+	pm package_manager.PackageManager,
+	rollbackService *rollback.Service,
+	catalogService *catalog.Service,
+	healthService *health.Service,
+	auditService *audit.Service,
+	coordinatorService *coordinator.Service,
+	statusService *status.Service,
+	webhookService *webhook.Service,
+) *Service {
+	return &Service{
+		logger:          l,
+		packageManager:  pm,
+		rollbackService: rollbackService,
+		catalog:         catalogService,
+		health:          healthService,
+		audit:           auditService,
+		coordinator:     coordinatorService,
+		status:          statusService,
+		webhooks:        webhookService,
+	}
+}
+
+// Upgrade installs the latest available version of the package and returns its state afterwards.
+func (s *Service) Upgrade(ctx context.Context, packageName string, output io.Writer) (Result, error) {
+	return s.audited(ctx, s.updateOperation(packageName, audit.OperationUpdate), packageName, output, func(ctx context.Context, output io.Writer) (Result, error) {
+		return s.withRollbackPoint(ctx, packageName, output, func() error {
+			return s.packageManager.UpgradePackage(ctx, packageName, output)
+		})
+	})
+}
+
+// Install installs the exact package version, which must be offered by the dvnet repository.
+func (s *Service) Install(ctx context.Context, packageName, version string, output io.Writer) (Result, error) {
+	return s.audited(ctx, s.updateOperation(packageName, audit.OperationInstall), packageName, output, func(ctx context.Context, output io.Writer) (Result, error) {
+		return s.withRollbackPoint(ctx, packageName, output, func() error {
+			return s.packageManager.InstallPackage(ctx, packageName, version, output)
+		})
+	})
+}
+
+// Rollback reinstalls the version the package had before the last upgrade.
+func (s *Service) Rollback(ctx context.Context, packageName string, output io.Writer) (Result, error) {
+	return s.audited(ctx, audit.OperationRollback, packageName, output, func(ctx context.Context, output io.Writer) (Result, error) {
+		return s.rollback(ctx, packageName, output)
+	})
+}
+
+// CompleteSelfUpdate records the outcome of a self-update or rollback of the updater which restarted it.
+// It is called on startup.
+func (s *Service) CompleteSelfUpdate(ctx context.Context) {
+	last, ok, err := s.audit.Last(s.catalog.Self())
+	if err != nil {
+		s.logger.Error("failed to read audit log", err)
+		return
+	}
+	if !ok || !s.restarts(last.Operation, last.Package) || last.Status != audit.StatusStarted {
+		return
+	}
+
+	pkg, err := s.installed(ctx, last.Package)
+	if err == nil && pkg.InstalledVersion == last.VersionBefore {
+		err = fmt.Errorf("updater restarted with the version %s it had before the %s", pkg.InstalledVersion, last.Operation)
+	}
+
+	last.VersionAfter = pkg.InstalledVersion
+	s.webhooks.Notify(event(outcome(last.Operation, err), &last, err))
+	if err = s.audit.Finish(&last, nil, err); err != nil {
+		s.logger.Error("failed to write audit record", err, "pkg", last.Package)
+	}
+}
+
+// audited waits for the operation lock, records the operation in the audit log and notifies webhooks.
+// Operations replacing the updater are recorded as started beforehand, the updater is restarted once they
+// succeed and CompleteSelfUpdate records the outcome.
+func (s *Service) audited(ctx context.Context, op audit.Operation, packageName string, output io.Writer, fn func(ctx context.Context, output io.Writer) (Result, error)) (Result, error) {
+	priority := coordinator.PriorityNormal
+	if op == audit.OperationSelfUpdate {
+		priority = coordinator.PriorityLow
+	}
+
+	ctx, release, err := s.coordinator.Acquire(ctx, coordinator.Operation{Name: string(op), Package: packageName, Priority: priority})
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+
+	record := s.audit.Begin(ctx, op, packageName)
+	if before, err := s.packageManager.GetInstalledPackage(ctx, packageName); err == nil {
+		record.VersionBefore = before.InstalledVersion
+	}
+
+	if s.restarts(op, packageName) {
+		started := *record
+		started.Status = audit.StatusStarted
+		if err := s.audit.Append(started); err != nil {
+			s.logger.Error("failed to write audit record", err, "pkg", packageName)
+		}
+
+		notifyCtx, cancel := context.WithTimeout(ctx, restartNotifyTimeout)
+		s.webhooks.Deliver(notifyCtx, event(webhook.EventSelfUpdateRestarting, record, nil))
+		cancel()
+	} else if op != audit.OperationRollback {
+		s.webhooks.Notify(event(webhook.EventUpgradeStarted, record, nil))
+	}
+
+	captured := s.audit.NewOutput()
+	res, err := fn(ctx, io.MultiWriter(output, captured))
+	s.status.Invalidate(packageName)
+
+	metrics.ObserveUpgrade(packageName, string(op), record.Time, err)
+
+	record.VersionAfter = res.Package.InstalledVersion
+	s.webhooks.Notify(event(outcome(op, err), record, err))
+	if auditErr := s.audit.Finish(record, captured, err); auditErr != nil {
+		s.logger.Error("failed to write audit record", auditErr, "pkg", packageName)
+	}
+
+	return res, err
+}
+
+// restarts reports whether the operation replaces the running updater.
+func (s *Service) restarts(op audit.Operation, packageName string) bool {
+	return op == audit.OperationSelfUpdate || (op == audit.OperationRollback && packageName == s.catalog.Self())
+}
+
+func (s *Service) updateOperation(packageName string, op audit.Operation) audit.Operation {
+	if packageName == s.catalog.Self() {
+		return audit.OperationSelfUpdate
+	}
+
+	return op
+}
+
+// outcome returns the webhook event of a finished operation.
+func outcome(op audit.Operation, err error) webhook.EventType {
+	switch {
+	case err != nil:
+		return webhook.EventUpgradeFailed
+	case op == audit.OperationRollback:
+		return webhook.EventRolledBack
+	default:
+		return webhook.EventUpgradeSucceeded
+	}
+}
+
+func event(t webhook.EventType, record *audit.Record, err error) webhook.Event {
+	e := webhook.Event{
+		Type:          t,
+		Package:       record.Package,
+		Operation:     string(record.Operation),
+		Requester:     record.Requester,
+		VersionBefore: record.VersionBefore,
+		VersionAfter:  record.VersionAfter,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e
+}
+
+func (s *Service) rollback(ctx context.Context, packageName string, output io.Writer) (Result, error) {
+	record, err := s.rollbackService.Last(packageName)
+	if err != nil {
+		return Result{}, err
+	}
+
+	s.logger.Info("rolling back package", "pkg", packageName, "version", record.Version)
+	if err = s.packageManager.DowngradePackage(ctx, packageName, record.Version, output); err != nil {
+		return Result{}, err
+	}
+
+	if err = s.rollbackService.Pop(packageName); err != nil {
+		s.logger.Error("failed to forget rollback version", err, "pkg", packageName)
+	}
+
+	pkg, err := s.installed(ctx, packageName)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// package scripts restart the updater after upgrades only, the old code keeps running otherwise
+	if packageName == s.catalog.Self() {
+		if err = s.restartSelf(ctx, output); err != nil {
+			return Result{Package: pkg}, err
+		}
+	}
+
+	return Result{Package: pkg, RolledBack: true}, nil
+}
+
+// restartSelf restarts the updater unit. The updater is stopped before systemctl returns.
+func (s *Service) restartSelf(ctx context.Context, output io.Writer) error {
+	unit := s.catalog.Self() + ".service"
+	s.logger.Info("restarting the updater", "unit", unit)
+
+	cmd := exec.CommandContext(ctx, "sudo", "systemctl", "restart", unit) //nolint:gosec
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to restart %s: %w", unit, err)
+	}
+
+	return nil
+}
+
+// withRollbackPoint remembers the installed version before running fn. The record is dropped again
+// when fn succeeds without changing the installed version. A changed package is health checked and
+// rolled back automatically when the check fails.
+func (s *Service) withRollbackPoint(ctx context.Context, packageName string, output io.Writer, fn func() error) (Result, error) {
+	recorded := false
+	before, err := s.packageManager.GetInstalledPackage(ctx, packageName)
+	if err != nil {
+		s.logger.Warn("installed version is unknown, rollback point is not recorded", "pkg", packageName, "err", err)
+	} else if err = s.rollbackService.Push(packageName, before.InstalledVersion); err != nil {
+		s.logger.Error("failed to record rollback version", err, "pkg", packageName)
+	} else {
+		recorded = true
+	}
+
+	if err = fn(); err != nil {
+		// a failed operation usually leaves the package as it was, the point would roll back to itself
+		if recorded {
+			if current, checkErr := s.packageManager.GetInstalledPackage(ctx, packageName); checkErr == nil && current.InstalledVersion == before.InstalledVersion {
+				s.forgetRollbackPoint(packageName)
+			}
+		}
+		return Result{}, err
+	}
+
+	after, err := s.installed(ctx, packageName)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if before.InstalledVersion == after.InstalledVersion {
+		if recorded {
+			s.forgetRollbackPoint(packageName)
+		}
+		return Result{Package: after}, nil
+	}
+
+	return s.verify(ctx, after, recorded, output)
+}
+
+func (s *Service) forgetRollbackPoint(packageName string) {
+	if err := s.rollbackService.Pop(packageName); err != nil {
+		s.logger.Error("failed to forget rollback version", err, "pkg", packageName)
+	}
+}
+
+// verify runs the package health check and rolls the package back when it fails.
+func (s *Service) verify(ctx context.Context, pkg package_manager.Package, canRollback bool, output io.Writer) (Result, error) {
+	pkgConf, ok := s.catalog.Get(pkg.Name)
+	if !ok {
+		return Result{Package: pkg}, nil
+	}
+
+	res := Result{Package: pkg, Health: s.health.Check(ctx, pkgConf)}
+	if res.Health == nil || res.Health.Healthy {
+		return res, nil
+	}
+
+	if !canRollback {
+		return res, fmt.Errorf("%w: %s, previous version is unknown", ErrUnhealthy, res.Health.Error)
+	}
+
+	s.logger.Warn("package is unhealthy, rolling back", "pkg", pkg.Name, "version", pkg.InstalledVersion)
+	rolledBack, err := s.Rollback(ctx, pkg.Name, output)
+	if err != nil {
+		return res, fmt.Errorf("%w: %s, rollback failed: %w", ErrUnhealthy, res.Health.Error, err)
+	}
+
+	res.Package = rolledBack.Package
+	res.RolledBack = true
+	return res, fmt.Errorf("%w: %s, rolled back to %s", ErrUnhealthy, res.Health.Error, rolledBack.Package.InstalledVersion)
+}
+
+func (s *Service) installed(ctx context.Context, packageName string) (package_manager.Package, error) {
+	pkg, err := s.packageManager.GetInstalledPackage(ctx, packageName)
+	if err != nil {
+		return package_manager.Package{}, fmt.Errorf("get installed package %s: %w", packageName, err)
+	}
+
+	return pkg, nil
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventUpdateAvailable      EventType = "update_available"
+	EventUpgradeStarted       EventType = "upgrade_started"
+	EventUpgradeSucceeded     EventType = "upgrade_succeeded"
+	EventUpgradeFailed        EventType = "upgrade_failed"
+	EventRolledBack           EventType = "rolled_back"
+	EventSelfUpdateRestarting EventType = "self_update_restarting"
+)
+
+type Event struct {
+	ID               uuid.UUID `json:"id"`
+	Type             EventType `json:"type"`
+	Time             time.Time `json:"time"`
+	Host             string    `json:"host,omitempty"`
+	Package          string    `json:"package"`
+	Operation        string    `json:"operation,omitempty"`
+	Requester        string    `json:"requester,omitempty"`
+	VersionBefore    string    `json:"version_before,omitempty"`
+	VersionAfter     string    `json:"version_after,omitempty"`
+	AvailableVersion string    `json:"available_version,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>\n<body>", sent in the X-Signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,235 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dv-net/dv-updater/internal/config"
+	"github.com/dv-net/dv-updater/internal/metrics"
+	"github.com/dv-net/dv-updater/pkg/logger"
+	"github.com/dv-net/dv-updater/pkg/retry"
+
+	"github.com/google/uuid"
+)
+
+const userAgent = "dv-updater-webhook"
+
+type endpoint struct {
+	conf  config.WebhookConfig
+	queue chan Event
+}
+
+func (e *endpoint) subscribed(t EventType) bool {
+	return len(e.conf.Events) == 0 || slices.Contains(e.conf.Events, string(t))
+}
+
+// Service posts events to the configured webhook endpoints. Every endpoint has its own queue,
+// so events reach an endpoint in order and a failing endpoint does not delay the others.
+type Service struct {
+	logger    logger.Logger
+	conf      config.WebhooksConfig
+	client    *http.Client
+	host      string
+	endpoints []*endpoint
+
+	mu        sync.Mutex
+	announced map[string]string
+}
+
+func NewService(l logger.Logger, conf config.WebhooksConfig) *Service {
+	host, _ := os.Hostname()
+
+	s := &Service{
+		logger:    l,
+		conf:      conf,
+		client:    &http.Client{Timeout: conf.Timeout},
+		host:      host,
+		announced: make(map[string]string),
+	}
+	for _, ec := range conf.Endpoints {
+		s.endpoints = append(s.endpoints, &endpoint{
+			conf:  ec,
+			queue: make(chan Event, conf.QueueSize),
+		})
+	}
+
+	return s
+}
+
+// Run delivers queued events until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	for _, e := range s.endpoints {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case event := <-e.queue:
+					_ = s.send(ctx, e, event)
+				}
+			}
+		}()
+	}
+}
+
+// Notify queues the event for delivery. The event is dropped when the queue of an endpoint is full.
+func (s *Service) Notify(event Event) {
+	event = s.complete(event)
+	for _, e := range s.endpoints {
+		if !e.subscribed(event.Type) {
+			continue
+		}
+
+		select {
+		case e.queue <- event:
+		default:
+			s.logger.Warn("webhook queue is full, event dropped", "url", e.conf.URL, "event", event.Type)
+		}
+	}
+}
+
+// Deliver sends the event right away and waits until every endpoint got it or ctx is done.
+// It is used before the updater restarts, queued events would be lost then.
+func (s *Service) Deliver(ctx context.Context, event Event) {
+	event = s.complete(event)
+
+	var wg sync.WaitGroup
+	for _, e := range s.endpoints {
+		if !e.subscribed(event.Type) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.send(ctx, e, event)
+		}()
+	}
+	wg.Wait()
+}
+
+// Flush delivers the queued events and returns once the queues are empty or ctx is done.
+// It is used by commands exiting without Run.
+func (s *Service) Flush(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range s.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				select {
+				case event := <-e.queue:
+					_ = s.send(ctx, e, event)
+				default:
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// UpdateAvailable notifies about a newer package version once per version.
+func (s *Service) UpdateAvailable(packageName, installed, available string) {
+	s.mu.Lock()
+	if s.announced[packageName] == available {
+		s.mu.Unlock()
+		return
+	}
+	s.announced[packageName] = available
+	s.mu.Unlock()
+
+	s.Notify(Event{
+		Type:             EventUpdateAvailable,
+		Package:          packageName,
+		VersionBefore:    installed,
+		AvailableVersion: available,
+	})
+}
+
+func (s *Service) complete(event Event) Event {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Host = s.host
+
+	return event
+}
+
+// send posts the event, retrying network errors and 5xx or 429 answers with backoff.
+func (s *Service) send(ctx context.Context, e *endpoint, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode webhook event: %w", err)
+	}
+
+	err = retry.New(
+		retry.WithPolicy(retry.PolicyBackoff),
+		retry.WithDelay(s.conf.RetryDelay),
+		retry.WithMaxAttempts(s.conf.MaxAttempts),
+		retry.WithContext(ctx),
+		retry.WithOnRetry(func(attempt int, err error) {
+			metrics.RetryHook("webhook")(attempt, err)
+			s.logger.Warn("webhook delivery failed, retrying", "url", e.conf.URL, "event", event.Type, "attempt", attempt, "err", err)
+		}),
+	).Do(func() error {
+		err := s.post(ctx, e.conf, event, body)
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", retry.ErrExit, ctx.Err())
+		}
+		return err
+	})
+
+	metrics.WebhookDelivery(string(event.Type), err)
+	if err != nil {
+		s.logger.Error("webhook delivery failed", err, "url", e.conf.URL, "event", event.Type, "id", event.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) post(ctx context.Context, conf config.WebhookConfig, event Event, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: build webhook request: %w", retry.ErrExit, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Webhook-Id", event.ID.String())
+	req.Header.Set("X-Webhook-Event", string(event.Type))
+	if conf.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", Sign(conf.Secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: webhook answered %d", retry.ErrExit, resp.StatusCode)
+	}
+}
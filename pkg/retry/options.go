@@ -31,6 +31,13 @@ func WithDebug(debug bool) Option {
 	}
 }
 
+// WithOnRetry sets a callback called with the attempt number and its error before every retry.
+func WithOnRetry(fn func(attempt int, err error)) Option {
+	return func(r *Retry) {
+		r.onRetry = fn
+	}
+}
+
 func WithContext(ctx context.Context) Option {
 	return func(r *Retry) {
 		r.ctx = ctx
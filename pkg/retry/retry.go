@@ -13,6 +13,7 @@ type Retry struct {
 	policy      Policy
 	delay       time.Duration
 	debug       bool
+	onRetry     func(attempt int, err error)
 }
 
 var (
@@ -48,8 +49,9 @@ func (r *Retry) Do(fn func() error) error {
 }
 
 func (r *Retry) linearRetry(fn func() error) error {
+	var err error
 	for attempt := 1; attempt <= r.maxAttempts; attempt++ {
-		err := fn()
+		err = fn()
 		if err == nil {
 			return nil
 		}
@@ -59,18 +61,20 @@ func (r *Retry) linearRetry(fn func() error) error {
 		}
 
 		if attempt < r.maxAttempts {
+			r.notify(attempt, err)
 			if r.debug {
 				fmt.Printf("linear Retry attempt %d failed, retrying in %s...\n", attempt, r.delay)
 			}
-			time.Sleep(r.delay)
+			r.sleep(r.delay)
 		}
 	}
-	return fmt.Errorf("linear retry failed after %d attempts", r.maxAttempts)
+	return fmt.Errorf("linear retry failed after %d attempts: %w", r.maxAttempts, err)
 }
 
 func (r *Retry) backoffRetry(fn func() error) error {
+	var err error
 	for attempt := 1; attempt <= r.maxAttempts; attempt++ {
-		err := fn()
+		err = fn()
 		if err == nil {
 			return nil
 		}
@@ -80,14 +84,15 @@ func (r *Retry) backoffRetry(fn func() error) error {
 		}
 
 		if attempt < r.maxAttempts {
+			r.notify(attempt, err)
 			delay := r.delay * (1 << (attempt - 1)) // Increase *2 every attempt
 			if r.debug {
 				fmt.Printf("backoff Retry attempt %d failed, retrying in %s...\n", attempt, delay)
 			}
-			time.Sleep(delay)
+			r.sleep(delay)
 		}
 	}
-	return fmt.Errorf("backoff retry failed after %d attempts", r.maxAttempts)
+	return fmt.Errorf("backoff retry failed after %d attempts: %w", r.maxAttempts, err)
 }
 
 func (r *Retry) infiniteRetry(fn func() error) error {
@@ -98,7 +103,7 @@ func (r *Retry) infiniteRetry(fn func() error) error {
 	resCh := make(chan error, 1)
 	go func() {
 		defer close(resCh)
-		for {
+		for attempt := 1; ; attempt++ {
 			select {
 			case <-r.ctx.Done():
 				return
@@ -113,13 +118,36 @@ func (r *Retry) infiniteRetry(fn func() error) error {
 					return
 				}
 
+				r.notify(attempt, err)
 				if r.debug {
 					fmt.Printf("initnite retry attempt\n")
 				}
-				time.Sleep(r.delay)
+				r.sleep(r.delay)
 			}
 		}
 	}()
 
 	return <-resCh
 }
+
+// sleep waits for the delay, a context set by WithContext cuts it short.
+func (r *Retry) sleep(delay time.Duration) {
+	if r.ctx == nil {
+		time.Sleep(delay)
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-r.ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func (r *Retry) notify(attempt int, err error) {
+	if r.onRetry != nil {
+		r.onRetry(attempt, err)
+	}
+}